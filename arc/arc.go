@@ -0,0 +1,371 @@
+package arc
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/juguagua/gCache/lru"
+	"github.com/juguagua/gCache/zset"
+)
+
+// ARC：自适应替换缓存。缓存分为T1(只访问过一次)和T2(访问过多次)两个LRU链表，
+// 并为它们各自维护一个只记录key的幽灵链表B1和B2，记录最近从T1和T2淘汰的数据。
+// 命中B1说明T1太小(偏向最近访问)，命中B2说明T2太小(偏向频繁访问)，
+// 据此自动调整T1的目标大小p，从而在两种访问模式之间自适应。
+// 这里的大小均以字节计算，幽灵链表记录被淘汰数据的大小，不保存value。
+
+// Warning: arc包不提供并发一致机制
+
+const (
+	expiresZSetKey = ""
+	// 每次移除过期键数量
+	removeExpireN = 10
+)
+
+type listID int
+
+const (
+	t1 listID = iota
+	t2
+	b1
+	b2
+)
+
+// arcList 一个按LRU排序的链表 链表头部为最近访问的数据
+type arcList struct {
+	ll     *list.List
+	length int // 链表中数据的字节数
+}
+
+// entry 定义双向链表节点所存储的对象 幽灵链表中的entry没有value
+type entry struct {
+	key   string
+	value lru.Value
+	size  int
+	list  listID
+}
+
+// Cache ARC缓存
+type Cache struct {
+	capacity        int  // 缓存容量
+	maxEntries      int  // 最大数据数量 不包含幽灵链表 为0时不限制
+	accountOverhead bool // 是否将每个entry的额外开销计入缓存大小
+	p               int  // T1的目标字节数
+	lists           [4]*arcList
+	cache           map[string]*list.Element // 包含幽灵链表中的key
+	onEvicted       lru.OnEvicted            // 可选，在entry被移除的时候执行
+	expires         *zset.SortedSet          // 过期键集合
+}
+
+// New 创建指定最大容量的ARC缓存。
+// 当maxBytes为0时，代表cache无内存限制，无限存放
+func New(maxBytes int, onEvicted lru.OnEvicted) *Cache {
+	c := &Cache{
+		capacity:  maxBytes,
+		cache:     make(map[string]*list.Element),
+		onEvicted: onEvicted,
+		expires:   zset.New(),
+	}
+	for i := range c.lists {
+		c.lists[i] = &arcList{ll: list.New()}
+	}
+	return c
+}
+
+// SetCapacity 调整缓存容量，容量变小时立即淘汰数据，为0时不限制
+func (c *Cache) SetCapacity(maxBytes int) {
+	c.capacity = maxBytes
+	c.p = min(c.p, maxBytes)
+	c.evict()
+}
+
+// SetMaxEntries 设置最大数据数量，超出后按ARC规则淘汰数据，为0时不限制
+func (c *Cache) SetMaxEntries(n int) {
+	c.maxEntries = n
+	c.evict()
+}
+
+// SetAccountOverhead 设置是否将每个entry的估算额外开销计入缓存大小
+// 开启后容量限制更接近实际的堆内存占用
+func (c *Cache) SetAccountOverhead(account bool) {
+	if c.accountOverhead == account {
+		return
+	}
+	c.accountOverhead = account
+	for _, l := range c.lists {
+		for e := l.ll.Front(); e != nil; e = e.Next() {
+			ent := e.Value.(*entry)
+			var size int
+			switch {
+			case ent.value != nil:
+				size = c.size(ent.key, ent.value)
+			case account: // 幽灵链表没有value 只调整entry的开销
+				size = ent.size + lru.EntryOverhead
+			default:
+				size = ent.size - lru.EntryOverhead
+			}
+			l.length += size - ent.size
+			ent.size = size
+		}
+	}
+	c.evict()
+}
+
+// Get 从缓存获取对应key的value 命中后移动到T2头部
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	element, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	ent := element.Value.(*entry)
+	if ent.list == b1 || ent.list == b2 {
+		return nil, false
+	}
+	// 移除过期的键
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		c.removeElement(element, lru.EvictExpired)
+		return nil, false
+	}
+	c.move(element, t2)
+	return ent.value, true
+}
+
+// Peek 获取对应key的value 不晋升到T2 已过期的value视为不存在但不会被移除
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	element, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	ent := element.Value.(*entry)
+	if ent.value == nil {
+		return nil, false
+	}
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		return nil, false
+	}
+	return ent.value, true
+}
+
+// Add 添加数据到缓存
+func (c *Cache) Add(key string, value lru.Value) {
+	size := c.size(key, value)
+	if element, ok := c.cache[key]; ok {
+		ent := element.Value.(*entry)
+		switch ent.list {
+		case t1, t2:
+			// 已缓存 更新value并视为一次访问
+			c.lists[ent.list].length += size - ent.size
+			old := ent.value
+			ent.value, ent.size = value, size
+			c.move(element, t2)
+			if c.onEvicted != nil {
+				c.onEvicted(key, old, lru.EvictReplaced)
+			}
+		case b1:
+			// 命中B1 增大T1的目标大小
+			c.p = min(c.p+max(ent.size, c.lists[b2].length*ent.size/max(c.lists[b1].length, 1)), c.capacity)
+			c.resurrect(element, value, size)
+		case b2:
+			// 命中B2 减小T1的目标大小
+			c.p = max(c.p-max(ent.size, c.lists[b1].length*ent.size/max(c.lists[b2].length, 1)), 0)
+			c.resurrect(element, value, size)
+		}
+	} else {
+		c.push(&entry{key: key, value: value, size: size, list: t1})
+	}
+	// 如果有超时时间则设置
+	if !value.Expire().IsZero() {
+		c.expires.ZAdd(expiresZSetKey, value.Expire().UnixNano(), key)
+	} else {
+		// 没有则删除
+		c.expires.ZRem(expiresZSetKey, key)
+	}
+	// 淘汰过期的key
+	if c.capacity != 0 {
+		c.removeExpire(removeExpireN)
+	}
+	c.evict()
+}
+
+// Remove 移除某个键
+func (c *Cache) Remove(key string) {
+	if element, ok := c.cache[key]; ok {
+		ent := element.Value.(*entry)
+		if ent.list == b1 || ent.list == b2 {
+			c.detach(element)
+			delete(c.cache, key)
+			return
+		}
+		c.removeElement(element, lru.EvictRemoved)
+	}
+}
+
+// Len 返回数据数量 不包含幽灵链表
+func (c *Cache) Len() int {
+	return c.lists[t1].ll.Len() + c.lists[t2].ll.Len()
+}
+
+// Bytes 返回当前缓存占用的字节数 不包含幽灵链表，开启额外开销统计时包含估算的额外开销
+func (c *Cache) Bytes() int {
+	return c.lists[t1].length + c.lists[t2].length
+}
+
+// LogicalBytes 返回key和value的字节数
+func (c *Cache) LogicalBytes() int {
+	if c.accountOverhead {
+		return c.Bytes() - c.overhead()
+	}
+	return c.Bytes()
+}
+
+// EstimatedBytes 返回包含链表节点、map、过期集合等额外开销的估算字节数 不包含幽灵链表
+func (c *Cache) EstimatedBytes() int {
+	return c.LogicalBytes() + c.overhead()
+}
+
+// 缓存中数据的估算额外开销
+func (c *Cache) overhead() int {
+	return c.Len()*lru.EntryOverhead + c.expires.ZCard(expiresZSetKey)*lru.ExpireOverhead
+}
+
+// 数据计入缓存大小的字节数
+func (c *Cache) size(key string, value lru.Value) int {
+	if c.accountOverhead {
+		return len(key) + value.Len() + lru.Overhead(value)
+	}
+	return len(key) + value.Len()
+}
+
+// 将数据放入指定链表的头部
+func (c *Cache) push(ent *entry) {
+	l := c.lists[ent.list]
+	c.cache[ent.key] = l.ll.PushFront(ent)
+	l.length += ent.size
+}
+
+// 将数据从所属链表摘除
+func (c *Cache) detach(e *list.Element) *entry {
+	ent := e.Value.(*entry)
+	l := c.lists[ent.list]
+	l.ll.Remove(e)
+	l.length -= ent.size
+	return ent
+}
+
+// 将数据移动到指定链表的头部
+func (c *Cache) move(e *list.Element, to listID) {
+	ent := c.detach(e)
+	ent.list = to
+	c.push(ent)
+}
+
+// 幽灵链表中的key再次被添加 重新进入T2
+func (c *Cache) resurrect(e *list.Element, value lru.Value, size int) {
+	ent := c.detach(e)
+	ent.value, ent.size, ent.list = value, size, t2
+	c.push(ent)
+}
+
+// 淘汰数据直到满足容量和数量限制 并限制幽灵链表的大小
+func (c *Cache) evict() {
+	if c.maxEntries != 0 {
+		for c.Len() > c.maxEntries {
+			c.replace()
+		}
+		// 与容量一样 幽灵链表与对应缓存链表的数量之和不超过数量限制 全部链表之和不超过两倍数量限制
+		for c.lists[t1].ll.Len()+c.lists[b1].ll.Len() > c.maxEntries && c.lists[b1].ll.Len() > 0 {
+			c.dropGhost(b1)
+		}
+		for len(c.cache) > 2*c.maxEntries && c.lists[b2].ll.Len() > 0 {
+			c.dropGhost(b2)
+		}
+	}
+	if c.capacity == 0 {
+		return
+	}
+	for c.Bytes() > c.capacity {
+		c.replace()
+	}
+	// 幽灵链表与对应缓存链表之和不超过容量 全部链表之和不超过两倍容量
+	for c.lists[t1].length+c.lists[b1].length > c.capacity && c.lists[b1].ll.Len() > 0 {
+		c.dropGhost(b1)
+	}
+	for c.Bytes()+c.lists[b1].length+c.lists[b2].length > 2*c.capacity && c.lists[b2].ll.Len() > 0 {
+		c.dropGhost(b2)
+	}
+}
+
+// 根据目标大小p选择从T1或T2淘汰 被淘汰的key进入对应的幽灵链表
+func (c *Cache) replace() {
+	from, ghost := t2, b2
+	if c.lists[t1].ll.Len() > 0 && (c.lists[t1].length > c.p || c.lists[t2].ll.Len() == 0) {
+		from, ghost = t1, b1
+	}
+	back := c.lists[from].ll.Back()
+	ent := c.detach(back)
+	value := ent.value
+	if !value.Expire().IsZero() {
+		c.expires.ZRem(expiresZSetKey, ent.key)
+	}
+	ent.value, ent.list = nil, ghost
+	c.push(ent)
+	if c.onEvicted != nil {
+		c.onEvicted(ent.key, value, lru.EvictCapacity)
+	}
+}
+
+func (c *Cache) dropGhost(id listID) {
+	ent := c.detach(c.lists[id].ll.Back())
+	delete(c.cache, ent.key)
+}
+
+// 移除指定键，删除过期时间，调用回调函数
+func (c *Cache) removeElement(e *list.Element, reason lru.EvictReason) {
+	ent := c.detach(e)
+	delete(c.cache, ent.key)
+	// 移除过期键
+	if !ent.value.Expire().IsZero() {
+		c.expires.ZRem(expiresZSetKey, ent.key)
+	}
+	if c.onEvicted != nil {
+		c.onEvicted(ent.key, ent.value, reason)
+	}
+}
+
+// RemoveExpired 主动移除最多n个已过期的键
+// 返回实际移除的数量
+func (c *Cache) RemoveExpired(n int) int {
+	return n - c.removeExpire(n)
+}
+
+// 移除过期的键
+// 返回未删除的数量
+func (c *Cache) removeExpire(n int) int {
+	for n > 0 && c.expires.ZCard(expiresZSetKey) > 0 {
+		values := c.expires.ZRangeWithScores(expiresZSetKey, 0, 0)
+		key, expireNano := values[0].(string), values[1].(int64)
+		// 第一个键都没超时，结束循环
+		if expireNano > time.Now().UnixNano() {
+			break
+		}
+		if element, ok := c.cache[key]; ok && element.Value.(*entry).value != nil {
+			c.removeElement(element, lru.EvictExpired)
+		}
+		n--
+	}
+	return n
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
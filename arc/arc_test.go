@@ -0,0 +1,154 @@
+package arc
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/juguagua/gCache/lru"
+)
+
+type String struct {
+	s      string
+	expire time.Time
+}
+
+func (s *String) Len() int {
+	return len(s.s)
+}
+
+func (s *String) Expire() time.Time {
+	return s.expire
+}
+
+func TestCache_Get(t *testing.T) {
+	arc := New(0, nil)
+	testKey, testValue := "key1", &String{s: "value1"}
+	arc.Add(testKey, testValue)
+	if value, ok := arc.Get(testKey); !ok || value.(*String) != testValue {
+		t.Fatalf("cache hit %v:%v failed\n", testKey, testValue)
+	}
+	if _, ok := arc.Get("key2"); ok {
+		t.Fatalf("hit not cache key=key2\n")
+	}
+}
+
+func TestCache_Capacity(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "key3"
+	v1, v2, v3 := &String{s: "value1"}, &String{s: "value2"}, &String{s: "value3"}
+	capacity := len(k1) + len(k2) + v1.Len() + v2.Len()
+	var evicted []string
+	arc := New(capacity, func(key string, value lru.Value, reason lru.EvictReason) {
+		evicted = append(evicted, key)
+	})
+	arc.Add(k1, v1)
+	arc.Add(k2, v2)
+	// k1被访问两次进入T2 应淘汰只访问过一次的k2
+	arc.Get(k1)
+	arc.Add(k3, v3)
+	if arc.Bytes() > capacity || arc.Len() != 2 {
+		t.Fatalf("bytes %d exceed capacity %d, len=%d\n", arc.Bytes(), capacity, arc.Len())
+	}
+	if len(evicted) != 1 || evicted[0] != k2 {
+		t.Fatalf("expect %v evicted, got %v\n", k2, evicted)
+	}
+	if _, ok := arc.Get(k1); !ok {
+		t.Fatalf("frequent key %v should not be evicted\n", k1)
+	}
+}
+
+func TestCache_Adapt(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "key3"
+	v1, v2, v3 := &String{s: "value1"}, &String{s: "value2"}, &String{s: "value3"}
+	capacity := len(k1) + len(k2) + v1.Len() + v2.Len()
+	arc := New(capacity, nil)
+	arc.Add(k1, v1)
+	arc.Add(k2, v2)
+	arc.Get(k2)     // k2进入T2
+	arc.Add(k3, v3) // k1被淘汰进入B1
+	if _, ok := arc.Get(k1); ok {
+		t.Fatalf("key %v should be evicted\n", k1)
+	}
+	if arc.lists[b1].ll.Len() != 1 {
+		t.Fatalf("evicted key should be recorded in b1\n")
+	}
+	// 命中B1 T1的目标大小增大 k1进入T2
+	arc.Add(k1, v1)
+	if arc.p == 0 {
+		t.Fatalf("p should grow after ghost hit in b1\n")
+	}
+	if value, ok := arc.Get(k1); !ok || value != v1 {
+		t.Fatalf("get key %v failed\n", k1)
+	}
+	if arc.Bytes() > capacity {
+		t.Fatalf("bytes %d exceed capacity %d\n", arc.Bytes(), capacity)
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	arc := New(0, nil)
+	arc.Add("key1", &String{s: "value1"})
+	arc.Remove("key1")
+	if _, ok := arc.Get("key1"); ok || arc.Len() != 0 || arc.Bytes() != 0 {
+		t.Fatalf("remove key1 failed, len=%d\n", arc.Len())
+	}
+}
+
+func TestCache_Expire(t *testing.T) {
+	arc := New(0, nil)
+	arc.Add("key1", &String{s: "value1", expire: time.Now().Add(100 * time.Millisecond)})
+	if _, ok := arc.Get("key1"); !ok {
+		t.Fatalf("get key1 failed\n")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := arc.Get("key1"); ok || arc.Len() != 0 {
+		t.Fatalf("expire key1 failed, len=%d\n", arc.Len())
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	arc := New(0, nil)
+	arc.SetMaxEntries(2)
+	arc.Add("key1", &String{s: "value1"})
+	arc.Get("key1")
+	arc.Add("key2", &String{s: "value2"})
+	arc.Add("key3", &String{s: "value3"})
+	if _, ok := arc.Get("key2"); ok || arc.Len() != 2 {
+		t.Fatalf("max entries failed, len=%d\n", arc.Len())
+	}
+	if _, ok := arc.Get("key1"); !ok {
+		t.Fatalf("frequently used key1 should be kept\n")
+	}
+	for i := 0; i < 10; i++ {
+		arc.Add(strconv.Itoa(i), &String{s: "value"})
+	}
+	if len(arc.cache) > 4 {
+		t.Fatalf("ghost lists should be bounded by max entries, got %d keys\n", len(arc.cache))
+	}
+}
+
+func TestCache_AccountOverhead(t *testing.T) {
+	k1, v1 := "key1", &String{s: "value1", expire: time.Now().Add(time.Hour)}
+	arc := New(0, nil)
+	arc.Add(k1, v1)
+	logical := len(k1) + v1.Len()
+	if arc.Bytes() != logical || arc.LogicalBytes() != logical {
+		t.Fatalf("expect %d logical bytes, got %d\n", logical, arc.Bytes())
+	}
+	if estimated := logical + lru.EntryOverhead + lru.ExpireOverhead; arc.EstimatedBytes() != estimated {
+		t.Fatalf("expect %d estimated bytes, got %d\n", estimated, arc.EstimatedBytes())
+	}
+	arc.SetAccountOverhead(true)
+	if arc.Bytes() != arc.EstimatedBytes() || arc.LogicalBytes() != logical {
+		t.Fatalf("expect bytes with overhead after enabling, got %d\n", arc.Bytes())
+	}
+
+	// 计入额外开销后 容量只够存放一个entry
+	arc = New(2*(logical+lru.EntryOverhead)-1, nil)
+	arc.SetAccountOverhead(true)
+	arc.Add("key1", &String{s: "value1"})
+	arc.Add("key2", &String{s: "value2"})
+	if arc.Len() != 1 {
+		t.Fatalf("expect 1 entry with overhead accounted, len=%d\n", arc.Len())
+	}
+}
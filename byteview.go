@@ -13,8 +13,10 @@ import "time"
 	可以被恶意修改。因此需要将slice封装成只读的ByteView
 */
 type ByteView struct {
-	b      []byte
-	expire time.Time // 过期时间
+	b       []byte
+	expire  time.Time // 过期时间
+	version uint64    // 版本号 由数据源定义 0表示无版本
+	flags   uint32    // 由使用方定义的标志位
 }
 
 func NewByteView(b []byte, expire time.Time) ByteView {
@@ -28,6 +30,22 @@ func (v ByteView) Expire() time.Time {
 	return v.expire
 }
 
+// WithMeta 返回设置了版本号和标志位的ByteView
+func (v ByteView) WithMeta(version uint64, flags uint32) ByteView {
+	v.version, v.flags = version, flags
+	return v
+}
+
+// Version 返回版本号
+func (v ByteView) Version() uint64 {
+	return v.version
+}
+
+// Flags 返回标志位
+func (v ByteView) Flags() uint32 {
+	return v.flags
+}
+
 func (v ByteView) Len() int {
 	return len(v.b)
 }
@@ -1,45 +1,473 @@
 package gcache
 
 import (
+	"github.com/juguagua/gCache/arc"
+	"github.com/juguagua/gCache/lfu"
 	"github.com/juguagua/gCache/lru"
+	"github.com/juguagua/gCache/tinylfu"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// cache 模块负责提供对lru模块的并发控制
+// cache 模块负责提供对淘汰算法的并发控制
 
-// 这样设计可以进行cache和算法的分离，比如现在有多种缓存模块可选，只需替换cache成员即可
+// Policy 缓存淘汰算法需要实现的接口
+// 实现无需保证并发安全，并发控制由cache负责
+type Policy interface {
+	Add(key string, value lru.Value)
+	Get(key string) (value lru.Value, ok bool)
+	Remove(key string)
+	Len() int   // 数据数量
+	Bytes() int // 当前占用的字节数
+}
+
+// EvictReason 数据被移除的原因
+type EvictReason = lru.EvictReason
+
+const (
+	EvictCapacity = lru.EvictCapacity // 超出容量被淘汰
+	EvictExpired  = lru.EvictExpired  // 过期被移除
+	EvictRemoved  = lru.EvictRemoved  // 被主动删除
+	EvictReplaced = lru.EvictReplaced // 被新的value覆盖
+)
+
+// EvictionHook 数据从缓存中移除时执行的回调函数
+// 回调在持有缓存分片锁时同步执行，因此不能在回调中访问同一个Group
+type EvictionHook func(key string, value ByteView, reason EvictReason)
+
+// Expirer 支持主动清理过期数据的淘汰算法可以实现该接口
+// 未实现的淘汰算法只会在访问时惰性删除过期数据
+type Expirer interface {
+	// RemoveExpired 移除最多n个已过期的数据，返回实际移除的数量
+	RemoveExpired(n int) int
+}
+
+// EntryLimiter 支持限制数据数量的淘汰算法可以实现该接口 内置的淘汰算法均已实现
+// 未实现的淘汰算法不受Group.SetMaxEntries限制
+type EntryLimiter interface {
+	SetMaxEntries(n int)
+}
+
+// MemoryAccounter 能够估算实际内存占用的淘汰算法可以实现该接口 内置的淘汰算法均已实现
+// 未实现的淘汰算法按照 lru.EntryOverhead 估算每个数据的额外开销 且不受Group.SetAccountOverhead影响
+type MemoryAccounter interface {
+	// SetAccountOverhead 设置是否将额外开销计入容量限制
+	SetAccountOverhead(account bool)
+	LogicalBytes() int   // key和value的字节数
+	EstimatedBytes() int // 包含额外开销的估算字节数
+}
+
+// Peeker 支持无副作用读取的淘汰算法可以实现该接口 内置的淘汰算法均已实现
+// 用于比较版本号等内部读取 不改变访问顺序、访问频次和命中统计
+// 未实现的淘汰算法使用Get代替 内部读取会被视为一次访问
+type Peeker interface {
+	Peek(key string) (value lru.Value, ok bool)
+}
+
+// Writer 对加载填充有准入规则的淘汰算法可以实现该接口
+// Group.Set等显式写入通过Set直接进入缓存 未实现的淘汰算法对显式写入同样使用Add
+type Writer interface {
+	// Set 显式写入数据 数据应直接进入缓存 不受准入规则限制
+	Set(key string, value lru.Value)
+}
+
+// Resizer 支持调整容量的淘汰算法可以实现该接口，用于在多个Group之间分配全局内存预算
+type Resizer interface {
+	// SetCapacity 调整容量，容量变小时立即淘汰数据
+	SetCapacity(maxBytes int)
+}
+
+// PolicyStats 淘汰算法的命中率和准入统计 可以用于比较不同淘汰算法的效果
+type PolicyStats = tinylfu.Stats
+
+// PolicyStatser 自行统计命中率和准入情况的淘汰算法可以实现该接口
+// 未实现的淘汰算法使用cache记录的命中次数 准入次数为0
+type PolicyStatser interface {
+	Stats() PolicyStats
+}
+
+// MemoryStats 缓存的内存占用统计
+type MemoryStats struct {
+	Items          int64 // 数据数量
+	LogicalBytes   int64 // key和value的字节数
+	EstimatedBytes int64 // 包含链表节点、map、过期集合等额外开销的估算字节数
+}
+
+// PolicyFunc 创建指定最大容量的淘汰算法，maxBytes为0代表无内存限制
+type PolicyFunc func(maxBytes int, onEvicted lru.OnEvicted) Policy
+
+// LRUPolicy 默认的淘汰算法
+func LRUPolicy(maxBytes int, onEvicted lru.OnEvicted) Policy {
+	return lru.New(maxBytes, onEvicted)
+}
+
+// LFUPolicy LFU淘汰算法，适合访问频次稳定的场景
+func LFUPolicy(maxBytes int, onEvicted lru.OnEvicted) Policy {
+	return lfu.New(maxBytes, onEvicted)
+}
+
+// LFUAgingPolicy 返回开启访问次数衰减的LFU淘汰算法，适合热点随时间变化的场景
+// 每个分片每经过period次访问，该分片所有key的访问次数减半
+func LFUAgingPolicy(period int) PolicyFunc {
+	return func(maxBytes int, onEvicted lru.OnEvicted) Policy {
+		c := lfu.New(maxBytes, onEvicted)
+		c.SetAging(period)
+		return c
+	}
+}
+
+// LRUKPolicy 返回LRU-K淘汰算法，key需要被加载k次才会进入缓存，用于抵抗批量扫描造成的缓存污染
+// historyLen为历史队列能记录的key数量
+func LRUKPolicy(k int, historyLen int) PolicyFunc {
+	return func(maxBytes int, onEvicted lru.OnEvicted) Policy {
+		return lru.NewK(k, historyLen, maxBytes, onEvicted)
+	}
+}
+
+// TinyLFUPolicy W-TinyLFU淘汰算法，根据访问频次决定新数据能否进入缓存，适合访问偏斜的场景
+func TinyLFUPolicy(maxBytes int, onEvicted lru.OnEvicted) Policy {
+	return tinylfu.New(maxBytes, onEvicted)
+}
+
+// ARCPolicy ARC淘汰算法，根据访问模式在偏重最近访问和偏重频繁访问之间自动调整
+func ARCPolicy(maxBytes int, onEvicted lru.OnEvicted) Policy {
+	return arc.New(maxBytes, onEvicted)
+}
+
+const (
+	defaultShards = 16      // 默认分片数量
+	minShardBytes = 1 << 10 // 每个分片的最小容量，避免容量较小时分片过多导致淘汰不准确
+
+	expireSampleN    = 20 // 主动过期每轮每个分片最多移除的数量
+	expireRepeatRate = 4  // 一轮移除数量超过 expireSampleN/expireRepeatRate 时认为过期数据较多，继续清理
+	expireTimeRate   = 4  // 每次清理最多占用清理间隔的 1/expireTimeRate
+)
+
+// 这样设计可以进行cache和算法的分离，比如现在有多种缓存模块可选，只需替换newPolicy即可
+// cache按key的哈希值分为多个分片，每个分片有独立的锁和淘汰算法，容量在分片间平分，
+// 从而避免所有协程竞争同一把锁
 type cache struct {
-	mu         sync.Mutex
-	lru        *lru.Cache
+	once       sync.Once
+	shards     []*shard
+	shardsN    int        // 分片数量 为0时使用defaultShards
+	newPolicy  PolicyFunc // 为nil时使用LRUPolicy
 	cacheBytes int
+	maxEntries int           // 最大数据数量 为0时不限制
+	overhead   bool          // 容量限制是否包含每个数据的额外开销
+	expired    int64         // 主动过期清理的数据数量
+	stopExpire chan struct{} // 关闭后停止主动过期清理
+	onEvicted  EvictionHook  // 可选，在数据被移除的时候执行
+	gets       AtomicInt     // 查询次数
+	hits       AtomicInt     // 命中次数
+	evictions  [4]AtomicInt  // 按原因统计的移除数量
+}
+
+// shard 负责提供对淘汰算法的并发控制
+type shard struct {
+	mu         sync.Mutex
+	policy     Policy
+	capacity   int // 分片容量
+	maxEntries int // 分片的最大数据数量 为0时不限制
+}
+
+// 初始化分片 容量或数量限制较小时减少分片数量
+func (c *cache) init() {
+	c.once.Do(func() {
+		n := c.shardsN
+		if n <= 0 {
+			n = defaultShards
+		}
+		if c.cacheBytes != 0 && c.cacheBytes/n < minShardBytes {
+			n = c.cacheBytes / minShardBytes
+			if n < 1 {
+				n = 1
+			}
+		}
+		// 保证每个分片至少能存放一个数据 且各分片之和恰好为数量限制
+		if c.maxEntries != 0 && c.maxEntries < n {
+			n = c.maxEntries
+		}
+		c.shards = make([]*shard, n)
+		for i := range c.shards {
+			c.shards[i] = &shard{capacity: shardCapacity(c.cacheBytes, n), maxEntries: shardEntries(c.maxEntries, n, i)}
+		}
+	})
+}
+
+// 将总容量平分给n个分片 总容量不为0时每个分片至少为1
+func shardCapacity(cacheBytes int, n int) int {
+	capacity := cacheBytes / n
+	if cacheBytes != 0 && capacity == 0 {
+		capacity = 1
+	}
+	return capacity
+}
+
+// 将数量限制分给n个分片 不能整除的部分分给前面的分片
+func shardEntries(maxEntries int, n int, i int) int {
+	entries := maxEntries / n
+	if i < maxEntries%n {
+		entries++
+	}
+	return entries
+}
+
+// 根据key的FNV-1a哈希值选择分片
+func (c *cache) shard(key string) *shard {
+	c.init()
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return c.shards[hash%uint32(len(c.shards))]
 }
 
 func (c *cache) add(key string, value ByteView) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.New(c.cacheBytes, nil)
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.addLocked(s, key, value)
+}
+
+// addIfNewer 添加数据 若已缓存的数据版本号更大则保留已缓存的数据
+// 避免较慢返回的旧版本覆盖已写入的新版本 版本号为0时直接覆盖
+func (c *cache) addIfNewer(key string, value ByteView) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.staleLocked(s, key, value) {
+		return
+	}
+	c.addLocked(s, key, value)
+}
+
+// set 显式写入数据 淘汰算法实现了Writer时绕过其准入规则 版本号的处理同addIfNewer
+func (c *cache) set(key string, value ByteView) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.staleLocked(s, key, value) {
+		return
+	}
+	if w, ok := c.policyLocked(s).(Writer); ok {
+		w.Set(key, value)
+		return
 	}
-	c.lru.Add(key, value)
+	s.policy.Add(key, value)
 }
 
-func (c *cache) get(key string) (ByteView, bool) { // 注意：Get操作需要修改lru中的双向链表，需要使用互斥锁。
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+// staleLocked 已缓存的数据版本号是否大于value 调用方需持有分片的锁
+func (c *cache) staleLocked(s *shard, key string, value ByteView) bool {
+	if value.version == 0 || s.policy == nil {
+		return false
+	}
+	var old lru.Value
+	var ok bool
+	if peeker, isPeeker := s.policy.(Peeker); isPeeker {
+		old, ok = peeker.Peek(key)
+	} else {
+		old, ok = s.policy.Get(key)
+	}
+	return ok && old.(ByteView).version > value.version
+}
+
+// addLocked 添加数据 调用方需持有分片的锁
+func (c *cache) addLocked(s *shard, key string, value ByteView) {
+	c.policyLocked(s).Add(key, value)
+}
+
+// policyLocked 返回分片的淘汰算法 首次使用时创建 调用方需持有分片的锁
+func (c *cache) policyLocked(s *shard) Policy {
+	if s.policy == nil {
+		newPolicy := c.newPolicy
+		if newPolicy == nil {
+			newPolicy = LRUPolicy
+		}
+		s.policy = newPolicy(s.capacity, c.evicted)
+		if limiter, ok := s.policy.(EntryLimiter); ok && s.maxEntries != 0 {
+			limiter.SetMaxEntries(s.maxEntries)
+		}
+		if accounter, ok := s.policy.(MemoryAccounter); ok && c.overhead {
+			accounter.SetAccountOverhead(true)
+		}
+	}
+	return s.policy
+}
+
+// evicted 淘汰算法移除数据时的回调
+func (c *cache) evicted(key string, value lru.Value, reason EvictReason) {
+	if int(reason) < len(c.evictions) {
+		c.evictions[reason].Add(1)
+	}
+	if c.onEvicted != nil {
+		c.onEvicted(key, value.(ByteView), reason)
+	}
+}
+
+func (c *cache) get(key string) (ByteView, bool) { // 注意：Get操作可能需要修改淘汰算法的内部结构，需要使用互斥锁。
+	c.gets.Add(1)
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy == nil {
 		return ByteView{}, false
 	}
-	if v, ok := c.lru.Get(key); ok {
+	if v, ok := s.policy.Get(key); ok {
+		c.hits.Add(1)
 		return v.(ByteView), ok
 	}
 	return ByteView{}, false
 }
 
 func (c *cache) remove(key string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.policy == nil {
+		return
+	}
+	s.policy.Remove(key)
+}
+
+// startExpire 启动后台协程 每隔interval主动清理过期数据
+func (c *cache) startExpire(interval time.Duration) {
+	c.init()
+	if c.stopExpire != nil {
 		return
 	}
-	c.lru.Remove(key)
+	c.stopExpire = make(chan struct{})
+	go c.expireLoop(interval, c.stopExpire)
+}
+
+// stopExpireLoop 停止主动过期清理 未启动时是no-op
+func (c *cache) stopExpireLoop() {
+	if c.stopExpire != nil {
+		close(c.stopExpire)
+		c.stopExpire = nil
+	}
+}
+
+func (c *cache) expireLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.expireCycle(time.Now().Add(interval / expireTimeRate))
+		}
+	}
+}
+
+// expireCycle 参考Redis的主动过期策略：每个分片从最早过期的数据开始清理一批，
+// 若这一批中过期数据较多则继续清理，直到过期数据变少或者超出时间限制
+func (c *cache) expireCycle(deadline time.Time) {
+	for _, s := range c.shards {
+		for {
+			s.mu.Lock()
+			expirer, ok := s.policy.(Expirer)
+			if !ok {
+				s.mu.Unlock()
+				break
+			}
+			removed := expirer.RemoveExpired(expireSampleN)
+			s.mu.Unlock()
+			atomic.AddInt64(&c.expired, int64(removed))
+			if removed <= expireSampleN/expireRepeatRate {
+				break
+			}
+			if time.Now().After(deadline) {
+				return
+			}
+		}
+	}
+}
+
+// expiredCount 返回主动过期清理的数据数量
+func (c *cache) expiredCount() int64 {
+	return atomic.LoadInt64(&c.expired)
+}
+
+// memoryStats 统计所有分片的内存占用
+func (c *cache) memoryStats() MemoryStats {
+	c.init()
+	var stats MemoryStats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		if s.policy != nil {
+			items := s.policy.Len()
+			stats.Items += int64(items)
+			if accounter, ok := s.policy.(MemoryAccounter); ok {
+				stats.LogicalBytes += int64(accounter.LogicalBytes())
+				stats.EstimatedBytes += int64(accounter.EstimatedBytes())
+			} else {
+				stats.LogicalBytes += int64(s.policy.Bytes())
+				stats.EstimatedBytes += int64(s.policy.Bytes() + items*lru.EntryOverhead)
+			}
+		}
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// policyStats 汇总所有分片的淘汰算法统计
+func (c *cache) policyStats() PolicyStats {
+	c.init()
+	var stats PolicyStats
+	reported := false
+	for _, s := range c.shards {
+		s.mu.Lock()
+		if statser, ok := s.policy.(PolicyStatser); ok {
+			st := statser.Stats()
+			stats.Hits += st.Hits
+			stats.Misses += st.Misses
+			stats.Admitted += st.Admitted
+			stats.Rejected += st.Rejected
+			reported = true
+		}
+		s.mu.Unlock()
+	}
+	if !reported {
+		hits := c.hits.Get()
+		stats.Hits, stats.Misses = hits, c.gets.Get()-hits
+	}
+	return stats
+}
+
+// resize 调整总容量 仅对实现了Resizer的淘汰算法立即生效
+func (c *cache) resize(cacheBytes int) {
+	c.init()
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.capacity = shardCapacity(cacheBytes, len(c.shards))
+		if resizer, ok := s.policy.(Resizer); ok {
+			resizer.SetCapacity(s.capacity)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// bytes 返回所有分片计入容量限制的字节数
+func (c *cache) bytes() int {
+	c.init()
+	n := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		if s.policy != nil {
+			n += s.policy.Bytes()
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// hitCount 返回命中次数
+func (c *cache) hitCount() int64 {
+	return c.hits.Get()
 }
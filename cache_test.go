@@ -0,0 +1,147 @@
+package gcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCache_Shards(t *testing.T) {
+	c := &cache{cacheBytes: 16 * minShardBytes, shardsN: 4}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		c.add(key, NewByteView([]byte(key), time.Time{}))
+	}
+	if len(c.shards) != 4 {
+		t.Fatalf("expect 4 shards, got %d", len(c.shards))
+	}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		if v, ok := c.get(key); !ok || v.String() != key {
+			t.Fatalf("get key %s failed", key)
+		}
+	}
+	c.remove("1")
+	if _, ok := c.get("1"); ok {
+		t.Fatalf("remove key 1 failed")
+	}
+
+	// 容量较小时减少分片数量
+	small := &cache{cacheBytes: minShardBytes}
+	small.init()
+	if len(small.shards) != 1 {
+		t.Fatalf("expect 1 shard for small cache, got %d", len(small.shards))
+	}
+}
+
+func benchmarkCacheGet(b *testing.B, shards int) {
+	c := &cache{cacheBytes: 64 << 20, shardsN: shards}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		c.add(keys[i], NewByteView([]byte(keys[i]), time.Time{}))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheGet_1Shard(b *testing.B) {
+	benchmarkCacheGet(b, 1)
+}
+
+func BenchmarkCacheGet_16Shards(b *testing.B) {
+	benchmarkCacheGet(b, 16)
+}
+
+func BenchmarkCacheGet_64Shards(b *testing.B) {
+	benchmarkCacheGet(b, 64)
+}
+
+func TestCache_ActiveExpire(t *testing.T) {
+	c := &cache{}
+	expire := time.Now().Add(50 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		c.add(key, NewByteView([]byte(key), expire))
+	}
+	c.add("forever", NewByteView([]byte("forever"), time.Time{}))
+	c.startExpire(10 * time.Millisecond)
+	defer c.stopExpireLoop()
+
+	time.Sleep(200 * time.Millisecond)
+	if n := c.expiredCount(); n != 100 {
+		t.Fatalf("expect 100 keys expired, got %d", n)
+	}
+	if _, ok := c.get("forever"); !ok {
+		t.Fatalf("key without expire should not be removed")
+	}
+}
+
+// 测试LFU衰减策略 曾经的热点数据在衰减后可以被淘汰
+func TestLFUAgingPolicy(t *testing.T) {
+	v := NewByteView([]byte("v"), time.Time{})
+	policy := LFUAgingPolicy(4)(2*(len("key1")+v.Len()), nil)
+	policy.Add("key1", v)
+	for i := 0; i < 3; i++ { // 第4次访问触发衰减 key1: 4 -> 2
+		policy.Get("key1")
+	}
+	policy.Add("key2", v)
+	for i := 0; i < 3; i++ { // 第4次访问触发衰减 key1: 2 -> 1, key2: 4 -> 2
+		policy.Get("key2")
+	}
+	policy.Add("key3", v)
+	if _, ok := policy.Get("key1"); ok {
+		t.Fatal("previously hot key1 should be removed after aging")
+	}
+	if _, ok := policy.Get("key2"); !ok {
+		t.Fatal("key2 should be kept")
+	}
+}
+
+// 测试比较版本号时不改变淘汰算法的状态
+func TestCache_AddIfNewerPeek(t *testing.T) {
+	v := func(s string, version uint64) ByteView {
+		return NewByteView([]byte(s), time.Time{}).WithMeta(version, 0)
+	}
+	c := &cache{cacheBytes: 2 * (len("key1") + 2), shardsN: 1}
+	c.add("key1", v("v2", 2))
+	c.add("key2", v("v1", 1))
+	c.addIfNewer("key1", v("v1", 1))
+	// 被跳过的旧版本不应使key1变为最近访问
+	c.add("key3", v("v1", 1))
+	if _, ok := c.get("key1"); ok {
+		t.Fatal("version check should not refresh key1")
+	}
+
+	c = &cache{cacheBytes: 1 << 20, shardsN: 1, newPolicy: TinyLFUPolicy}
+	c.add("key1", v("v2", 2))
+	c.addIfNewer("key1", v("v1", 1))
+	if stats := c.policyStats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Fatalf("version check should not count as access, got %+v", stats)
+	}
+}
+
+// 测试分片后每个分片的W-TinyLFU窗口仍能存放新数据
+func TestCache_TinyLFUShards(t *testing.T) {
+	c := &cache{cacheBytes: 16 * minShardBytes, shardsN: 16, newPolicy: TinyLFUPolicy}
+	for i := 0; i < 32; i++ {
+		c.add(strconv.Itoa(i), NewByteView(make([]byte, 32), time.Time{}))
+	}
+	if len(c.shards) != 16 {
+		t.Fatalf("expect 16 shards, got %d", len(c.shards))
+	}
+	if stats := c.policyStats(); stats.Admitted != 0 || stats.Rejected != 0 {
+		t.Fatalf("new entries should stay in the window of each shard, got %+v", stats)
+	}
+	for i := 0; i < 32; i++ {
+		if _, ok := c.get(strconv.Itoa(i)); !ok {
+			t.Fatalf("get key %d failed", i)
+		}
+	}
+}
@@ -3,41 +3,62 @@ package gcache
 import (
 	"context"
 	"fmt"
-	pb "github.com/juguagua/gCache/gcachepb"
-	"github.com/juguagua/gCache/registry"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"strings"
+	"sync"
 	"time"
+
+	pb "github.com/juguagua/gCache/gcachepb"
+	"github.com/juguagua/gCache/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // client 模块实现cache访问其他远程节点 从而获取缓存的能力
 
+const (
+	defaultFetchTimeout = 10 * time.Second // ctx未设置deadline时单次远程请求的超时时间
+	defaultIdleTimeout  = 5 * time.Minute  // 连接空闲超过该时间将被关闭
+)
+
 type client struct {
-	name string // 服务名称 gcache/ip:addr
+	name      string    // 节点名称 服务名/ip:addr
+	addr      string    // 节点地址 ip:addr
+	discovery Discovery // 用于连接远程节点 由同一个server下的所有client共享
+
+	mu       sync.Mutex
+	conn     *grpc.ClientConn // 懒加载的长连接
+	lastUsed time.Time        // 最近一次使用连接的时间 用于空闲淘汰
 }
 
 // Fetch 从remote peer获取对应缓存值
-func (c *client) Fetch(group string, key string) (ByteView, error) {
-	// 创建一个etcd client
-	cli, err := clientv3.New(defaultEtcdConfig)
-	if err != nil {
-		return ByteView{}, err
-	}
-	defer cli.Close()
-	// 发现服务 取得与服务的连接
-	conn, err := registry.EtcdDial(cli, c.name)
+func (c *client) Fetch(ctx context.Context, group string, key string) (view ByteView, err error) {
+	ctx, span := startSpan(ctx, "gcache.client.Fetch", group, key)
+	span.SetAttributes(tracing.String("peer", c.addr))
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	conn, err := c.dial()
 	if err != nil {
 		return ByteView{}, err
 	}
-	defer conn.Close()
-	grpcClient := pb.NewGroupCacheClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := withDefaultTimeout(ctx)
 	defer cancel()
-	resp, err := grpcClient.Get(ctx, &pb.GetRequest{
+	ctx = injectTrace(ctx)
+	if isReplicaRead(ctx) {
+		ctx = metadata.AppendToOutgoingContext(ctx, replicaReadHeader, "1")
+	}
+	start := time.Now()
+	resp, err := pb.NewGroupCacheClient(conn).Get(ctx, &pb.GetRequest{
 		Group: group,
 		Key:   key,
 	})
+	observeSince(peerFetchLatency, start, group, c.addr)
 	if err != nil {
-		return ByteView{}, fmt.Errorf("could not get %s/%s from peer %s", group, key, c.name)
+		c.checkConn(conn, err)
+		return ByteView{}, peerError(ctx, err, "could not get %s/%s from peer %s", group, key, c.name)
 	}
 
 	var expire time.Time
@@ -48,11 +69,153 @@ func (c *client) Fetch(group string, key string) (ByteView, error) {
 		}
 	}
 
-	return ByteView{resp.Value, expire}, nil
+	return NewByteView(resp.Value, expire).WithMeta(resp.Version, resp.Flags), nil
+}
+
+// Set 将缓存值写入remote peer
+func (c *client) Set(ctx context.Context, group string, key string, value ByteView) error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	var expire int64
+	if !value.Expire().IsZero() {
+		expire = value.Expire().UnixNano()
+	}
+	_, err = pb.NewGroupCacheClient(conn).Set(ctx, &pb.SetRequest{
+		Group:   group,
+		Key:     key,
+		Value:   value.ByteSlice(),
+		Expire:  expire,
+		Version: value.Version(),
+		Flags:   value.Flags(),
+	})
+	if err != nil {
+		c.checkConn(conn, err)
+		return peerError(ctx, err, "could not set %s/%s to peer %s", group, key, c.name)
+	}
+	return nil
+}
+
+// Delete 删除remote peer上的缓存值
+func (c *client) Delete(ctx context.Context, group string, key string) error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	_, err = pb.NewGroupCacheClient(conn).Delete(ctx, &pb.DeleteRequest{
+		Group: group,
+		Key:   key,
+	})
+	if err != nil {
+		c.checkConn(conn, err)
+		return peerError(ctx, err, "could not delete %s/%s from peer %s", group, key, c.name)
+	}
+	return nil
 }
 
+// Stats 获取remote peer上group的统计信息
+func (c *client) Stats(ctx context.Context, group string) (GroupStats, error) {
+	conn, err := c.dial()
+	if err != nil {
+		return GroupStats{}, err
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	resp, err := pb.NewGroupCacheClient(conn).Stats(ctx, &pb.StatsRequest{Group: group})
+	if err != nil {
+		c.checkConn(conn, err)
+		return GroupStats{}, peerError(ctx, err, "could not get stats of %s from peer %s", group, c.name)
+	}
+	return groupStatsFromPB(resp), nil
+}
+
+// withDefaultTimeout 若ctx未设置deadline 则使用默认超时时间 避免请求无限阻塞
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, defaultFetchTimeout)
+}
+
+// peerError 包装远程请求的错误 ctx被取消或超时时包装ctx.Err() 调用方可以用errors.Is判断
+// 否则包装grpc返回的错误 调用方可以用errors.As取得grpc状态
+func peerError(ctx context.Context, err error, format string, args ...interface{}) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	return fmt.Errorf(format+": %w", append(args, err)...)
+}
+
+// dial 返回与远程节点的长连接 连接不存在时才进行拨号
+func (c *client) dial() (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastUsed = time.Now()
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	// 发现服务 取得与服务的连接
+	conn, err := c.discovery.Dial(c.name, c.addr)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// checkConn 根据请求错误判断连接是否已经失效 失效则关闭 下次请求时重新拨号
+// 连接由所有请求共享 单个请求被取消或超时不代表连接失效 因此只在Unavailable时关闭
+// 且只关闭发生错误的那个连接 避免关闭其他请求刚刚重新拨号的连接
+func (c *client) checkConn(conn *grpc.ClientConn, err error) {
+	if status.Code(err) != codes.Unavailable {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// closeIfIdle 连接空闲超过idle时关闭连接
+func (c *client) closeIfIdle(idle time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && time.Since(c.lastUsed) > idle {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// close 关闭连接 client之后仍然可用 下次请求时会重新拨号
+func (c *client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// String 返回远程节点的服务名称
+func (c *client) String() string {
+	return c.name
+}
+
+// NewClient 创建一个直接连接远程节点的Fetcher service的格式为 服务名/ip:port
 func NewClient(service string) *client {
-	return &client{name: service}
+	addr := service[strings.LastIndex(service, "/")+1:]
+	return newClient(service, addr, NewStaticDiscovery())
+}
+
+func newClient(service string, addr string, discovery Discovery) *client {
+	return &client{name: service, addr: addr, discovery: discovery}
 }
 
 // 测试Client是否实现了Fetcher接口
@@ -0,0 +1,222 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/juguagua/gCache/gcachepb"
+	"github.com/juguagua/gCache/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// 测试过期时间、版本号和标志位经由grpc传递
+func TestClient_FetchMeta(t *testing.T) {
+	expire := time.Now().Add(time.Hour)
+	NewGroup("meta", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), expire).WithMeta(7, 3), nil
+	}))
+	defer DestroyGroup("meta")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterGroupCacheServer(grpcServer, &server{addr: lis.Addr().String(), logger: logging.Nop()})
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{name: "gcache/" + lis.Addr().String(), conn: conn}
+	defer c.close()
+
+	view, err := c.Fetch(context.Background(), "meta", "Tom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.String() != "Tom" || !view.Expire().Equal(expire) || view.Version() != 7 || view.Flags() != 3 {
+		t.Fatalf("metadata lost: %+v", view)
+	}
+
+	if err := c.Set(context.Background(), "meta", "Jack", NewByteView([]byte("589"), time.Time{}).WithMeta(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := GetGroup("meta").mainCache.get("Jack"); !ok || v.Version() != 2 || v.Flags() != 1 {
+		t.Fatalf("metadata lost on set: %+v", v)
+	}
+}
+
+// countingDiscovery 记录拨号次数的服务发现
+type countingDiscovery struct {
+	Discovery
+	mu    sync.Mutex
+	dials int
+}
+
+func (d *countingDiscovery) Dial(service string, addr string) (*grpc.ClientConn, error) {
+	d.mu.Lock()
+	d.dials++
+	d.mu.Unlock()
+	return d.Discovery.Dial(service, addr)
+}
+
+func (d *countingDiscovery) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dials
+}
+
+// serveGroupCache 在addr上启动grpc服务 返回实际监听的地址和停止函数
+func serveGroupCache(t *testing.T, addr string) (string, func()) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterGroupCacheServer(grpcServer, &server{addr: lis.Addr().String(), logger: logging.Nop()})
+	go grpcServer.Serve(lis)
+	return lis.Addr().String(), grpcServer.Stop
+}
+
+// 测试多次请求复用同一个连接 单个请求被取消不会关闭共享的连接
+func TestClient_ConnReuse(t *testing.T) {
+	NewGroup("reuse", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("reuse")
+	addr, stop := serveGroupCache(t, "127.0.0.1:0")
+	defer stop()
+
+	d := &countingDiscovery{Discovery: NewStaticDiscovery()}
+	c := newClient("gcache/"+addr, addr, d)
+	defer c.close()
+	for i := 0; i < 3; i++ {
+		if _, err := c.Fetch(context.Background(), "reuse", "Tom"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d.count() != 1 {
+		t.Fatalf("expect 1 dial, got %d", d.count())
+	}
+
+	conn := c.conn
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Fetch(ctx, "reuse", "Jack"); err == nil {
+		t.Fatal("canceled fetch should fail")
+	}
+	if c.conn != conn || conn.GetState() == connectivity.Shutdown {
+		t.Fatal("canceled request should not close the shared conn")
+	}
+}
+
+// 测试节点不可用时关闭连接 节点恢复后重新拨号
+func TestClient_Reconnect(t *testing.T) {
+	NewGroup("reconnect", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("reconnect")
+	addr, stop := serveGroupCache(t, "127.0.0.1:0")
+
+	d := &countingDiscovery{Discovery: NewStaticDiscovery()}
+	c := newClient("gcache/"+addr, addr, d)
+	defer c.close()
+	if _, err := c.Fetch(context.Background(), "reconnect", "Tom"); err != nil {
+		t.Fatal(err)
+	}
+	stale := c.conn
+	stop()
+	if _, err := c.Fetch(context.Background(), "reconnect", "Tom"); err == nil {
+		t.Fatal("fetch from stopped peer should fail")
+	}
+	// 旧连接已失效 不应关闭其他请求重新拨号得到的连接
+	c.checkConn(stale, status.Error(codes.Unavailable, "unavailable"))
+
+	_, stop = serveGroupCache(t, addr)
+	defer stop()
+	if _, err := c.Fetch(context.Background(), "reconnect", "Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if d.count() != 2 || c.conn == stale {
+		t.Fatalf("expect redial after peer unavailable, dials=%d", d.count())
+	}
+	fresh := c.conn
+	c.checkConn(stale, status.Error(codes.Unavailable, "unavailable"))
+	if c.conn != fresh {
+		t.Fatal("error of stale conn should not close the fresh one")
+	}
+}
+
+// 测试空闲连接被关闭 下次请求时重新拨号
+func TestClient_CloseIfIdle(t *testing.T) {
+	d := &countingDiscovery{Discovery: NewStaticDiscovery()}
+	c := newClient("gcache/127.0.0.1:7001", "127.0.0.1:7001", d)
+	conn, err := c.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.closeIfIdle(time.Minute)
+	if c.conn != conn {
+		t.Fatal("recently used conn should not be closed")
+	}
+	c.lastUsed = time.Now().Add(-time.Hour)
+	c.closeIfIdle(time.Minute)
+	if c.conn != nil || conn.GetState() != connectivity.Shutdown {
+		t.Fatal("idle conn should be closed")
+	}
+	if _, err := c.dial(); err != nil || d.count() != 2 {
+		t.Fatalf("expect redial after idle close, dials=%d err=%v", d.count(), err)
+	}
+	c.close()
+}
+
+// 测试远程请求的错误保留了原因
+func TestClient_ErrorCause(t *testing.T) {
+	addr, stop := serveGroupCache(t, "127.0.0.1:0")
+	defer stop()
+	c := newClient("gcache/"+addr, addr, NewStaticDiscovery())
+	defer c.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := c.Fetch(ctx, "cause", "Tom"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect deadline exceeded, got %v", err)
+	}
+
+	_, err := c.Fetch(context.Background(), "cause", "Tom")
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) || se.GRPCStatus().Message() != "group not found" {
+		t.Fatalf("expect grpc status of group not found, got %v", err)
+	}
+}
+
+// 测试读副本标记经由grpc传递给远程节点
+func TestClient_ReplicaRead(t *testing.T) {
+	NewGroup("replicaread", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(strconv.FormatBool(isReplicaRead(ctx))), time.Time{}), nil
+	}))
+	defer DestroyGroup("replicaread")
+	addr, stop := serveGroupCache(t, "127.0.0.1:0")
+	defer stop()
+	c := newClient("gcache/"+addr, addr, NewStaticDiscovery())
+	defer c.close()
+
+	if view, err := c.Fetch(context.Background(), "replicaread", "Tom"); err != nil || view.String() != "false" {
+		t.Fatalf("expect normal read, got %v %v", view, err)
+	}
+	if view, err := c.Fetch(withReplicaRead(context.Background()), "replicaread", "Jack"); err != nil || view.String() != "true" {
+		t.Fatalf("expect replica read, got %v %v", view, err)
+	}
+}
@@ -18,6 +18,7 @@ type Consistence struct {
 	replicas int            // 虚拟节点倍数
 	ring     []int          // 哈希环
 	hashMap  map[int]string // 虚拟节点hash到真实节点名称的映射
+	weights  map[string]int // 节点权重 虚拟节点数量为replicas*weight
 }
 
 // New 创建一个一致性哈希结构
@@ -26,6 +27,7 @@ func New(replicas int, fn Hash) *Consistence {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		weights:  make(map[string]int),
 	}
 	if m.hash == nil { // 默认散列函数为crc32
 		m.hash = crc32.ChecksumIEEE
@@ -33,25 +35,45 @@ func New(replicas int, fn Hash) *Consistence {
 	return m
 }
 
-// Register 将各个peer注册到哈希环上
+// Register 将各个peer注册到哈希环上 权重均为1
 func (c *Consistence) Register(peersName ...string) {
 	for _, peerName := range peersName {
-		for i := 0; i < c.replicas; i++ { // 对于每一个节点都创建多个虚拟节点加入到哈希环中
-			hashValue := int(c.hash([]byte(strconv.Itoa(i) + peerName))) // 每个虚拟节点的哈希值为其编号加节点名称进行散列
-			c.ring = append(c.ring, hashValue)
-			c.hashMap[hashValue] = peerName
-		}
+		c.add(peerName, 1)
 	}
 	sort.Ints(c.ring) // 添加完后进行排序，打乱节点
 }
 
+// RegisterWeighted 以指定权重将peer注册到哈希环上 权重越大分得的key越多 weight小于1时视为1
+func (c *Consistence) RegisterWeighted(peerName string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	c.add(peerName, weight)
+	sort.Ints(c.ring)
+}
+
+// add 为peer创建replicas*weight个虚拟节点加入到哈希环中 调用方负责排序
+func (c *Consistence) add(peerName string, weight int) {
+	c.weights[peerName] = weight
+	for i := 0; i < c.replicas*weight; i++ {
+		hashValue := int(c.hash([]byte(strconv.Itoa(i) + peerName))) // 每个虚拟节点的哈希值为其编号加节点名称进行散列
+		c.ring = append(c.ring, hashValue)
+		c.hashMap[hashValue] = peerName
+	}
+}
+
 // Delete 从一致性哈希删除节点
 func (m *Consistence) Delete(keys ...string) {
 	for _, key := range keys { // 删除指定的节点
-		for i := 0; i < m.replicas; i++ {
+		weight, ok := m.weights[key]
+		if !ok {
+			continue
+		}
+		for i := 0; i < m.replicas*weight; i++ {
 			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
 			delete(m.hashMap, hash)
 		}
+		delete(m.weights, key)
 	}
 	newKeys := make([]int, 0, len(m.hashMap)) // 重建哈希环
 	for key := range m.hashMap {
@@ -12,7 +12,7 @@ func TestHash(t *testing.T) {
 	})
 
 	// 2, 4, 6, 12, 14, 16, 22, 24, 26
-	hash.Add("6", "4", "2")
+	hash.Register("6", "4", "2")
 
 	testCases := map[string]string{
 		"2":  "2",
@@ -22,20 +22,42 @@ func TestHash(t *testing.T) {
 	}
 
 	for k, v := range testCases {
-		if name := hash.Get(k); name != v {
+		if name := hash.GetPeer(k); name != v {
 			t.Errorf("expected %s but got %s\n", v, name)
 		}
 	}
 
 	// Adds 8, 18, 28
-	hash.Add("8")
+	hash.Register("8")
 
 	// 27 should now map to 8.
 	testCases["27"] = "8"
 
 	for k, v := range testCases {
-		if name := hash.Get(k); name != v {
+		if name := hash.GetPeer(k); name != v {
 			t.Errorf("expected %s but got %s\n", v, name)
 		}
 	}
 }
+
+func TestWeighted(t *testing.T) {
+	hash := New(50, nil)
+	hash.RegisterWeighted("heavy", 3)
+	hash.RegisterWeighted("light", 1)
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[hash.GetPeer(strconv.Itoa(i))]++
+	}
+	if counts["heavy"] < 2*counts["light"] {
+		t.Errorf("expected heavy peer to own most keys, got %v\n", counts)
+	}
+
+	hash.Delete("heavy")
+	if name := hash.GetPeer("1"); name != "light" {
+		t.Errorf("expected light but got %s\n", name)
+	}
+	if len(hash.ring) != 50 {
+		t.Errorf("expected 50 virtual nodes but got %d\n", len(hash.ring))
+	}
+}
@@ -0,0 +1,186 @@
+package gcache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juguagua/gCache/logging"
+	"github.com/juguagua/gCache/registry"
+	"google.golang.org/grpc"
+)
+
+// discovery 模块定义节点之间如何发现彼此
+// 默认通过etcd注册和发现节点 也可以使用任意registry.Registry 或者使用静态地址列表直接连接 无需任何外部服务
+
+// Discovery 定义了注册本节点和连接远程节点的能力
+type Discovery interface {
+	// Register 将本节点连同其元数据注册为service 阻塞直到stop收到信号或被关闭
+	Register(service string, ep registry.Endpoint, stop chan error) error
+	// Dial 建立与远程节点的连接 service为节点的服务名 addr为节点地址
+	Dial(service string, addr string) (*grpc.ClientConn, error)
+	// Close 释放Discovery持有的资源 之后仍可再次使用
+	Close() error
+}
+
+// PeerWatcher 可选接口 实现了该接口的Discovery可以让server自动感知节点的加入和离开
+type PeerWatcher interface {
+	// WatchPeers 监听service下的所有节点 节点变化时以完整的节点列表调用onChange
+	// 阻塞直到ctx取消或监听出错
+	WatchPeers(ctx context.Context, service string, onChange func(peers []registry.Endpoint)) error
+}
+
+// loggerSetter 可选接口 实现了该接口的Discovery和Registry使用server设置的日志
+type loggerSetter interface {
+	SetLogger(logger logging.Logger)
+}
+
+// CompatibleFunc 判断本节点local能否将请求路由给节点peer 不兼容的节点不会加入哈希环
+type CompatibleFunc func(local, peer registry.Endpoint) bool
+
+// SameMajorVersion 默认的兼容策略 主版本号相同的节点才能互相路由 任一方未声明版本时视为兼容
+// 滚动升级期间新旧主版本的节点各自组成哈希环 避免请求被路由到不兼容的节点
+func SameMajorVersion(local, peer registry.Endpoint) bool {
+	if local.Version == "" || peer.Version == "" {
+		return true
+	}
+	return majorVersion(local.Version) == majorVersion(peer.Version)
+}
+
+// majorVersion 返回形如v1.2.3的版本号中的主版本号
+func majorVersion(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexByte(version, '.'); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
+
+// registryDiscovery 基于registry.Registry的服务发现
+type registryDiscovery struct {
+	r       registry.Registry
+	backoff registry.Backoff // 注册失败后重试的退避策略
+	timeout time.Duration    // 单次注册的超时时间 注册中心不可用时避免注册无限阻塞
+
+	mu       sync.Mutex
+	handlers map[string]registry.StatusFunc // service/addr到注册状态回调的映射
+}
+
+// NewRegistryDiscovery 创建基于注册中心的服务发现 节点地址从注册中心获得后直接连接
+func NewRegistryDiscovery(r registry.Registry) Discovery {
+	return &registryDiscovery{r: r, timeout: defaultLeaseTTL, handlers: make(map[string]registry.StatusFunc)}
+}
+
+// NewEtcdDiscovery 创建基于etcd的服务发现 etcd的连接配置取自opts 单次注册最多等待一个租约过期时间
+func NewEtcdDiscovery(opts ServerOptions) Discovery {
+	opts = opts.withDefaults()
+	return &registryDiscovery{r: opts.etcdRegistry(), timeout: opts.LeaseTTL, handlers: make(map[string]registry.StatusFunc)}
+}
+
+// Register 注册节点 收到stop信号后注销
+// 注册失败或超时时以指数退避不断重试 节点在此期间照常提供服务 注册成功后重新加入集群
+// 收到stop信号时取消正在进行的注册
+func (d *registryDiscovery) Register(service string, ep registry.Endpoint, stop chan error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stopErr error
+	stopped := make(chan struct{})
+	go func() {
+		stopErr = <-stop
+		close(stopped)
+		cancel()
+	}()
+
+	backoff := d.backoff
+	for {
+		attemptCtx, attemptCancel := context.WithTimeout(ctx, d.timeout)
+		err := d.r.Register(attemptCtx, service, ep)
+		attemptCancel()
+		if err == nil {
+			break
+		}
+		select {
+		case <-stopped: // 注册因停止而取消
+			return stopErr
+		default:
+		}
+		d.notify(service, ep.Addr, registry.StatusLost, err)
+		select {
+		case <-stopped:
+			return stopErr
+		case <-time.After(backoff.Next()):
+		}
+	}
+	<-stopped
+	deregisterCtx, deregisterCancel := context.WithTimeout(context.Background(), d.timeout)
+	defer deregisterCancel()
+	d.r.Deregister(deregisterCtx, service, ep.Addr)
+	return stopErr
+}
+
+// SetStatusHandler 设置service下addr的注册状态回调 Registry实现了StatusNotifier时同样转发给Registry
+func (d *registryDiscovery) SetStatusHandler(service string, addr string, fn registry.StatusFunc) {
+	d.mu.Lock()
+	if fn == nil {
+		delete(d.handlers, service+"/"+addr)
+	} else {
+		d.handlers[service+"/"+addr] = fn
+	}
+	d.mu.Unlock()
+	if n, ok := d.r.(registry.StatusNotifier); ok {
+		n.SetStatusHandler(service, addr, fn)
+	}
+}
+
+// SetLogger 设置日志 Registry实现了SetLogger时转发给Registry
+func (d *registryDiscovery) SetLogger(logger logging.Logger) {
+	if l, ok := d.r.(loggerSetter); ok {
+		l.SetLogger(logger)
+	}
+}
+
+func (d *registryDiscovery) notify(service string, addr string, status registry.Status, err error) {
+	d.mu.Lock()
+	fn := d.handlers[service+"/"+addr]
+	d.mu.Unlock()
+	if fn != nil {
+		fn(service, addr, status, err)
+	}
+}
+
+// Dial 直接连接节点地址 连接是非阻塞的 断开后grpc会自动重连
+// 哈希环需要访问确定的节点 因此不通过服务名做负载均衡
+func (d *registryDiscovery) Dial(_ string, addr string) (*grpc.ClientConn, error) {
+	return grpc.Dial(addr, grpc.WithInsecure())
+}
+
+func (d *registryDiscovery) WatchPeers(ctx context.Context, service string, onChange func(peers []registry.Endpoint)) error {
+	return d.r.Watch(ctx, service, onChange)
+}
+
+func (d *registryDiscovery) Close() error {
+	return d.r.Close()
+}
+
+// staticDiscovery 静态地址列表的服务发现 直接连接SetPeers配置的节点地址
+type staticDiscovery struct{}
+
+// NewStaticDiscovery 创建静态服务发现 节点地址由SetPeers给出 不依赖任何外部服务
+func NewStaticDiscovery() Discovery {
+	return staticDiscovery{}
+}
+
+// Register 静态发现无需注册 阻塞直到stop收到信号或被关闭
+func (staticDiscovery) Register(_ string, _ registry.Endpoint, stop chan error) error {
+	return <-stop
+}
+
+// Dial 直接连接addr 连接是非阻塞的 断开后grpc会自动重连
+func (staticDiscovery) Dial(_ string, addr string) (*grpc.ClientConn, error) {
+	return grpc.Dial(addr, grpc.WithInsecure())
+}
+
+func (staticDiscovery) Close() error {
+	return nil
+}
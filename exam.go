@@ -1,6 +1,7 @@
 package gcache
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
@@ -18,10 +19,10 @@ func main() {
 	}
 	// 新建cache实例
 	group := NewGroup("scores", 2<<10, GetterFunc(
-		func(key string) (ByteView, error) {
+		func(ctx context.Context, key string) (ByteView, error) {
 			log.Println("[Mysql] search key", key)
 			if v, ok := mysql[key]; ok {
-				return ByteView{[]byte(v), time.Time{}}, nil
+				return NewByteView([]byte(v), time.Time{}), nil
 			}
 			return ByteView{}, fmt.Errorf("%s not exist", key)
 		}))
@@ -34,9 +35,9 @@ func main() {
 	}
 
 	// 设置同伴节点IP(包括自己)
-	// todo: 这里的peer地址从etcd获取(服务发现)
-
 	svr.SetPeers(addr)
+	// 启动后从etcd监听注册的节点 自动更新同伴节点
+	svr.SetPeerWatch(2 * time.Second)
 
 	// 将服务与cache绑定 因为cache和server是解耦合的
 	group.RegisterSvr(svr)
@@ -1,10 +1,13 @@
 package gcache
 
 import (
+	"context"
 	"fmt"
+	"github.com/juguagua/gCache/logging"
 	"github.com/juguagua/gCache/singleflight"
+	"github.com/juguagua/gCache/tracing"
 
-	"log"
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -13,17 +16,18 @@ import (
 // 换句话说，实现了填充缓存/命名划分缓存的能力
 
 // Getter 要求对象实现从数据源获取数据的能力
+// ctx携带调用方的deadline和取消信号 数据源应当遵守
 type Getter interface {
-	Get(key string) (ByteView, error) // 回调函数
+	Get(ctx context.Context, key string) (ByteView, error) // 回调函数
 }
 
 // GetterFunc 函数类型实现Getter接口
-type GetterFunc func(key string) (ByteView, error)
+type GetterFunc func(ctx context.Context, key string) (ByteView, error)
 
 // Get 通过实现Get方法，使得任意匿名函数func
 // 通过被GetterFunc(func)类型强制转换后，实现了 Getter 接口的能力
-func (f GetterFunc) Get(key string) (ByteView, error) {
-	return f(key)
+func (f GetterFunc) Get(ctx context.Context, key string) (ByteView, error) {
+	return f(ctx, key)
 }
 
 // Group 提供命名管理缓存/填充缓存的能力
@@ -32,9 +36,19 @@ type Group struct {
 	getter           Getter               // 数据源获取数据
 	mainCache        *cache               // 主缓存，并发缓存
 	hotCache         *cache               // 热点缓存
+	policy           PolicyFunc           // 主缓存使用的淘汰算法
+	hotPolicy        PolicyFunc           // 热点缓存使用的淘汰算法 为nil时与主缓存相同
+	shards           int                  // 缓存的分片数量 为0时使用默认值
+	expireInterval   time.Duration        // 主动过期清理的间隔 为0表示只惰性删除
+	overhead         bool                 // 容量限制是否包含每个数据的额外开销
+	memory           *MemoryManager       // 负责分配主缓存容量的内存管理器 为nil时使用cacheBytes
 	server           Picker               // 用于获取远程节点请求客户端
 	flight           *singleflight.Flight // 避免对同一个key多次加载造成缓存击穿
 	emptyKeyDuration time.Duration        // getter返回error时对应空值key的过期时间
+	ttl              time.Duration        // 未设置过期时间的value的默认存活时间 为0表示永不过期
+	ttlJitter        time.Duration        // 默认存活时间的随机抖动上限
+	stats            groupStats           // 统计信息
+	logger           logging.Logger       // 日志 热路径上只输出Debug级别
 }
 
 var (
@@ -56,6 +70,7 @@ func NewGroup(name string, cacheBytes int, getter Getter) *Group {
 			cacheBytes: cacheBytes,
 		},
 		flight: &singleflight.Flight{},
+		logger: logging.Default(),
 	}
 	mu.Lock()
 	defer mu.Unlock()
@@ -79,13 +94,37 @@ func GetGroup(name string) *Group {
 	return g
 }
 
+// allGroups 返回当前所有的缓存命名空间
+func allGroups() []*Group {
+	mu.RLock()
+	defer mu.RUnlock()
+	gs := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		gs = append(gs, g)
+	}
+	return gs
+}
+
 func DestroyGroup(name string) {
 	g := GetGroup(name)
 	if g != nil {
+		g.mainCache.stopExpireLoop()
+		if g.hotCache != nil {
+			g.hotCache.stopExpireLoop()
+		}
+		if g.memory != nil {
+			g.memory.Unregister(g)
+		}
+		mu.Lock()
+		delete(groups, name)
+		mu.Unlock()
+		if g.server == nil {
+			logging.Info(g.logger, "destroy cache", logging.Group(name))
+			return
+		}
 		svr := g.server.(*server)
 		svr.Stop()
-		delete(groups, name)
-		log.Printf("Destroy cache [%s %s]", name, svr.addr)
+		logging.Info(g.logger, "destroy cache", logging.Group(name), logging.F("addr", svr.addr))
 	}
 }
 
@@ -95,50 +134,292 @@ func (g *Group) SetEmptyWhenError(duration time.Duration) {
 	g.emptyKeyDuration = duration
 }
 
+// SetTTL 设置value的默认存活时间 仅对没有设置过期时间的value生效
+// 实际存活时间为 ttl + [0, jitter) 的随机值，避免大量key同时过期造成缓存雪崩
+// ttl为0表示该机制不生效
+func (g *Group) SetTTL(ttl time.Duration, jitter time.Duration) {
+	if ttl < 0 || jitter < 0 {
+		panic("ttl and jitter must not be negative")
+	}
+	g.ttl = ttl
+	g.ttlJitter = jitter
+}
+
+// SetPolicy 设置主缓存使用的淘汰算法，默认为LRUPolicy
+// 未通过SetHotCachePolicy单独设置时，热点缓存也使用该算法
+// 注意: 需要在Group开始缓存数据之前调用
+func (g *Group) SetPolicy(policy PolicyFunc) {
+	if policy == nil {
+		panic("nil PolicyFunc")
+	}
+	g.policy = policy
+	g.mainCache.newPolicy = policy
+	if g.hotCache != nil && g.hotPolicy == nil {
+		g.hotCache.newPolicy = policy
+	}
+}
+
+// SetHotCachePolicy 单独设置热点缓存使用的淘汰算法
+// 注意: 需要在Group开始缓存数据之前调用
+func (g *Group) SetHotCachePolicy(policy PolicyFunc) {
+	if policy == nil {
+		panic("nil PolicyFunc")
+	}
+	g.hotPolicy = policy
+	if g.hotCache != nil {
+		g.hotCache.newPolicy = policy
+	}
+}
+
+// SetCacheShards 设置主缓存和热点缓存的分片数量，分片越多锁竞争越少，但每个分片的容量越小
+// 注意: 需要在Group开始缓存数据之前调用
+func (g *Group) SetCacheShards(n int) {
+	if n <= 0 {
+		panic("shards must be greater than 0")
+	}
+	g.shards = n
+	g.mainCache.shardsN = n
+	if g.hotCache != nil {
+		g.hotCache.shardsN = n
+	}
+}
+
+// SetActiveExpire 开启主动过期清理，每隔interval在后台清理主缓存和热点缓存中的过期数据
+// 默认只在访问或写入时清理过期数据，数据很少被访问或者缓存无容量限制时过期数据会一直占用内存
+// 后台协程在DestroyGroup时停止
+func (g *Group) SetActiveExpire(interval time.Duration) {
+	if interval <= 0 {
+		panic("active expire interval must be greater than 0")
+	}
+	if g.expireInterval != 0 {
+		return
+	}
+	g.expireInterval = interval
+	g.mainCache.startExpire(interval)
+	if g.hotCache != nil {
+		g.hotCache.startExpire(interval)
+	}
+}
+
+// SetMaxEntries 设置主缓存的最大数据数量，在字节容量之外额外限制数据数量，为0时不限制
+// 数量限制在分片间平分，n小于分片数量时分片数量减少为n，内置的淘汰算法均支持
+// 自定义淘汰算法需要实现EntryLimiter才会生效
+// 注意: 需要在Group开始缓存数据之前调用
+func (g *Group) SetMaxEntries(n int) {
+	if n < 0 {
+		panic("max entries must not be negative")
+	}
+	g.mainCache.maxEntries = n
+}
+
+// SetAccountOverhead 设置主缓存和热点缓存的容量限制是否包含每个数据的估算额外开销
+// 开启后cacheBytes更接近实际的堆内存占用，内置的淘汰算法均支持，自定义淘汰算法需要实现MemoryAccounter才会生效
+// 注意: 需要在Group开始缓存数据之前调用
+func (g *Group) SetAccountOverhead(account bool) {
+	g.overhead = account
+	g.mainCache.overhead = account
+	if g.hotCache != nil {
+		g.hotCache.overhead = account
+	}
+}
+
+// MemoryStats 返回主缓存和热点缓存的内存占用，包括逻辑字节数和估算的实际字节数
+func (g *Group) MemoryStats() (main MemoryStats, hot MemoryStats) {
+	main = g.mainCache.memoryStats()
+	if g.hotCache != nil {
+		hot = g.hotCache.memoryStats()
+	}
+	return main, hot
+}
+
+// PolicyStats 返回主缓存和热点缓存淘汰算法的命中率和准入统计，按分片汇总
+// 可以用于在相同负载下比较不同淘汰算法的命中率
+func (g *Group) PolicyStats() (main PolicyStats, hot PolicyStats) {
+	main = g.mainCache.policyStats()
+	if g.hotCache != nil {
+		hot = g.hotCache.policyStats()
+	}
+	return main, hot
+}
+
+// SetEvictionHook 设置主缓存数据被移除时的回调，可以用于回写、记录日志或按原因统计
+// 热点缓存中的数据只是远程节点数据的副本，因此不会触发回调
+// 注意: 需要在Group开始缓存数据之前调用
+func (g *Group) SetEvictionHook(hook EvictionHook) {
+	g.mainCache.onEvicted = hook
+}
+
+// 主缓存和热点缓存的命中次数
+func (g *Group) hitCount() int64 {
+	n := g.mainCache.hitCount()
+	if g.hotCache != nil {
+		n += g.hotCache.hitCount()
+	}
+	return n
+}
+
+// ExpiredKeys 返回主动过期清理的数据数量
+func (g *Group) ExpiredKeys() int64 {
+	n := g.mainCache.expiredCount()
+	if g.hotCache != nil {
+		n += g.hotCache.expiredCount()
+	}
+	return n
+}
+
+// SetLogger 设置日志 为nil时丢弃所有日志
+func (g *Group) SetLogger(logger logging.Logger) {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	g.logger = logger
+}
+
 // SetHotCache 设置远程节点Hot Key-Value的缓存，避免频繁请求远程节点
 func (g *Group) SetHotCache(cacheBytes int) {
 	if cacheBytes <= 0 {
 		panic("hot cache must be greater than 0")
 	}
+	policy := g.hotPolicy
+	if policy == nil {
+		policy = g.policy
+	}
 	g.hotCache = &cache{
 		cacheBytes: cacheBytes,
+		newPolicy:  policy,
+		shardsN:    g.shards,
+		overhead:   g.overhead,
+	}
+	if g.expireInterval != 0 {
+		g.hotCache.startExpire(g.expireInterval)
 	}
 }
 
 // Get 从缓存获取key对应的value
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext 从缓存获取key对应的value
+// ctx会传递给远程节点请求和本地getter 用于控制超时和取消
+func (g *Group) GetContext(ctx context.Context, key string) (value ByteView, err error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is required")
 	}
 
+	ctx, span := startSpan(ctx, "gcache.Group.Get", g.name, key)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	g.stats.gets.Add(1)
 	if v, ok := g.mainCache.get(key); ok { // 先从主缓存获取
-		log.Println("[Cache] main cache hit")
+		g.stats.mainCacheHits.Add(1)
+		if g.logger.Enabled(logging.LevelDebug) {
+			logging.Debug(g.logger, "main cache hit", logging.Group(g.name), logging.KeyHash(key))
+		}
+		span.SetAttributes(tracing.String("cache", "main"))
 		return v, nil
 	}
 	if g.hotCache != nil {
 		if v, ok := g.hotCache.get(key); ok { // 主缓存没有看热点缓存
-			log.Println("[Cache] hot cache hit")
+			g.stats.hotCacheHits.Add(1)
+			if g.logger.Enabled(logging.LevelDebug) {
+				logging.Debug(g.logger, "hot cache hit", logging.Group(g.name), logging.KeyHash(key))
+			}
+			span.SetAttributes(tracing.String("cache", "hot"))
 			return v, nil
 		}
 	}
-	return g.load(key)
+	span.SetAttributes(tracing.String("cache", "miss"))
+	return g.load(ctx, key)
+}
+
+// Set 设置key对应的value
+// 若key归属于远程节点 则写入远程节点的主缓存 并清除本地热点缓存中的旧副本
+func (g *Group) Set(key string, value ByteView) error {
+	return g.SetContext(context.Background(), key, value)
+}
+
+// SetContext 同Set ctx用于控制远程请求的超时和取消
+func (g *Group) SetContext(ctx context.Context, key string, value ByteView) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	value = g.withDefaultTTL(value)
+	if g.server != nil {
+		if fetcher, ok := g.server.Pick(key); ok {
+			if err := fetcher.Set(ctx, g.name, key, value); err != nil {
+				return err
+			}
+			if g.hotCache != nil {
+				g.hotCache.remove(key)
+			}
+			return nil
+		}
+	}
+	g.setLocally(key, value)
+	return nil
+}
+
+// Delete 删除key对应的缓存
+// 若key归属于远程节点 则删除远程节点上的缓存 同时清除本地热点缓存中的副本
+func (g *Group) Delete(key string) error {
+	return g.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext 同Delete ctx用于控制远程请求的超时和取消
+func (g *Group) DeleteContext(ctx context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	if g.server != nil {
+		if fetcher, ok := g.server.Pick(key); ok {
+			if err := fetcher.Delete(ctx, g.name, key); err != nil {
+				return err
+			}
+		}
+	}
+	g.removeLocally(key)
+	return nil
 }
 
 // 加载缓存
-func (g *Group) load(key string) (ByteView, error) {
-	view, err := g.flight.Fly(key, func() (interface{}, error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
+	ctx, span := startSpan(ctx, "gcache.Group.load", g.name, key)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	g.stats.loads.Add(1)
+	// 加载在独立于单个调用者取消的ctx下进行 某个调用者取消不会使其他调用者失败
+	// 加载的deadline取等待者中最晚的deadline 远程请求据此设置超时 都没有deadline时使用默认超时
+	view, err := g.flight.Fly(ctx, key, func(ctx context.Context) (interface{}, error) {
+		g.stats.loadsExecuted.Add(1)
 		if g.server != nil { // 先判断是否需要从远程加载
 			if fetcher, ok := g.server.Pick(key); ok { // ok代表需要从远程加载
-				view, err := fetcher.Fetch(g.name, key)
+				if view, ok := g.loadFromReplica(ctx, key); ok {
+					return view, nil
+				}
+				start := time.Now()
+				view, err := fetcher.Fetch(ctx, g.name, key)
 				if err == nil {
+					g.stats.peerLoads.Add(1)
+					if g.logger.Enabled(logging.LevelDebug) {
+						logging.Debug(g.logger, "load from peer", logging.Group(g.name), logging.KeyHash(key),
+							logging.Peer(peerName(fetcher)), logging.Latency(time.Since(start)))
+					}
 					g.populateCache(key, view, g.hotCache)
 					return view, nil
 				}
-				log.Printf("[Cache] failed to get from peer key=%s, err=%v\n", key, err)
+				g.stats.peerErrors.Add(1)
+				span.SetAttributes(tracing.String("peer_error", err.Error()))
+				logging.Warn(g.logger, "failed to get from peer", logging.Group(g.name), logging.KeyHash(key),
+					logging.Peer(peerName(fetcher)), logging.Latency(time.Since(start)), logging.Err(err))
 			}
 		}
 		// 否则从本地加载
-		return g.loadLocally(key)
+		return g.loadLocally(ctx, key)
 	})
 	if err != nil {
 		return ByteView{}, err
@@ -146,10 +427,50 @@ func (g *Group) load(key string) (ByteView, error) {
 	return view.(ByteView), nil
 }
 
+// loadFromReplica key的归属节点在其他可用区时 先从本可用区的读副本加载
+// 读副本自身收到的请求不再转发给其他读副本 读副本不可用时返回false 由调用方访问归属节点
+func (g *Group) loadFromReplica(ctx context.Context, key string) (ByteView, bool) {
+	rp, ok := g.server.(ReplicaPicker)
+	if !ok || isReplicaRead(ctx) {
+		return ByteView{}, false
+	}
+	replica, ok := rp.PickReplica(key)
+	if !ok {
+		return ByteView{}, false
+	}
+	start := time.Now()
+	view, err := replica.Fetch(withReplicaRead(ctx), g.name, key)
+	if err != nil {
+		g.stats.peerErrors.Add(1)
+		logging.Warn(g.logger, "failed to get from zone replica", logging.Group(g.name), logging.KeyHash(key),
+			logging.Peer(peerName(replica)), logging.Latency(time.Since(start)), logging.Err(err))
+		return ByteView{}, false
+	}
+	g.stats.peerLoads.Add(1)
+	if g.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(g.logger, "load from zone replica", logging.Group(g.name), logging.KeyHash(key),
+			logging.Peer(peerName(replica)), logging.Latency(time.Since(start)))
+	}
+	g.populateCache(key, view, g.hotCache)
+	return view, true
+}
+
 // 从本地节点加载缓存值
-func (g *Group) loadLocally(key string) (ByteView, error) {
-	value, err := g.getter.Get(key)
+func (g *Group) loadLocally(ctx context.Context, key string) (value ByteView, err error) {
+	ctx, span := startSpan(ctx, "gcache.Group.loadLocally", g.name, key)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	start := time.Now()
+	value, err = g.getter.Get(ctx, key)
+	observeSince(loadLatency, start, g.name)
+	if g.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(g.logger, "load locally", logging.Group(g.name), logging.KeyHash(key),
+			logging.Latency(time.Since(start)), logging.Err(err))
+	}
 	if err != nil {
+		g.stats.localLoadErrs.Add(1)
 		if g.emptyKeyDuration == 0 {
 			return ByteView{}, err
 		}
@@ -158,10 +479,38 @@ func (g *Group) loadLocally(key string) (ByteView, error) {
 			expire: time.Now().Add(g.emptyKeyDuration),
 		}
 	}
+	g.stats.localLoads.Add(1)
+	value = g.withDefaultTTL(value)
 	g.populateCache(key, value, g.mainCache)
 	return value, nil
 }
 
+// 为没有过期时间的value设置默认过期时间
+func (g *Group) withDefaultTTL(value ByteView) ByteView {
+	if g.ttl == 0 || !value.expire.IsZero() {
+		return value
+	}
+	ttl := g.ttl
+	if g.ttlJitter > 0 {
+		ttl += time.Duration(rand.Int63n(int64(g.ttlJitter)))
+	}
+	value.expire = time.Now().Add(ttl)
+	return value
+}
+
+// 写入本地节点主缓存 热点缓存中的副本已过时需要清除
+func (g *Group) setLocally(key string, value ByteView) {
+	// 写入已过期的值等同于删除 不能让旧值继续留在缓存中
+	if !value.expire.IsZero() && !value.expire.After(time.Now()) {
+		g.removeLocally(key)
+		return
+	}
+	g.mainCache.set(key, value)
+	if g.hotCache != nil {
+		g.hotCache.remove(key)
+	}
+}
+
 // 从本地节点删除缓存
 func (g *Group) removeLocally(key string) {
 	g.mainCache.remove(key)
@@ -175,5 +524,9 @@ func (g *Group) populateCache(key string, value ByteView, cache *cache) {
 	if cache == nil {
 		return
 	}
-	cache.add(key, value)
+	// 已过期的值不再缓存
+	if !value.expire.IsZero() && !value.expire.After(time.Now()) {
+		return
+	}
+	cache.addIfNewer(key, value)
 }
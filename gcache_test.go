@@ -1,20 +1,26 @@
 package gcache
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/juguagua/gCache/logging"
+	"github.com/juguagua/gCache/lru"
 	"log"
 	"math"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
 func TestGetter(t *testing.T) {
-	var f Getter = GetterFunc(func(key string) (ByteView, error) {
+	var f Getter = GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
 		return NewByteView([]byte(key), time.Time{}), nil
 	})
 	key1 := "key1"
-	if value, err := f.Get(key1); err != nil || value.String() != key1 {
+	if value, err := f.Get(context.Background(), key1); err != nil || value.String() != key1 {
 		t.Errorf("getter expect %s but %s\n", key1, value)
 	}
 }
@@ -27,7 +33,7 @@ func TestGroup_Get(t *testing.T) {
 		"Sam":  "567",
 	}
 	loadCounts := make(map[string]int, len(db))
-	g := NewGroup("scores", 2<<10, GetterFunc(func(key string) (ByteView, error) {
+	g := NewGroup("scores", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
 		log.Printf("[SlowDB] sear key %s\n", key)
 		if v, ok := db[key]; ok {
 			loadCounts[key]++
@@ -52,10 +58,428 @@ func TestGroup_Get(t *testing.T) {
 
 func BenchmarkGet(b *testing.B) {
 	b.ReportAllocs()
-	g := NewGroup("scores", math.MaxInt, GetterFunc(func(key string) (ByteView, error) {
+	g := NewGroup("scores", math.MaxInt, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
 		return NewByteView([]byte(key), time.Time{}), nil
 	}))
 	for i := 0; i < b.N; i++ {
 		g.Get(strconv.Itoa(i))
 	}
 }
+
+// 测试Set和Delete方法
+func TestGroup_SetDelete(t *testing.T) {
+	loads := 0
+	g := NewGroup("setdelete", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		loads++
+		return NewByteView([]byte("db"), time.Time{}), nil
+	}))
+
+	if err := g.Set("Tom", NewByteView([]byte("630"), time.Time{})); err != nil {
+		t.Fatalf("set key Tom failed: %v", err)
+	}
+	if view, err := g.Get("Tom"); err != nil || view.String() != "630" || loads != 0 {
+		t.Fatalf("expect 630 from cache, got %s, loads=%d", view.String(), loads)
+	}
+	if err := g.Delete("Tom"); err != nil {
+		t.Fatalf("delete key Tom failed: %v", err)
+	}
+	if view, err := g.Get("Tom"); err != nil || view.String() != "db" || loads != 1 {
+		t.Fatalf("expect reload from getter after delete, got %s, loads=%d", view.String(), loads)
+	}
+	if err := g.Set("", ByteView{}); err == nil {
+		t.Fatalf("set empty key should fail")
+	}
+}
+
+// 测试写入已过期的值会删除旧值
+func TestGroup_SetExpired(t *testing.T) {
+	g := NewGroup("setexpired", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte("db"), time.Time{}), nil
+	}))
+	g.SetHotCache(2 << 10)
+	g.Set("Tom", NewByteView([]byte("v1"), time.Time{}))
+	g.hotCache.add("Tom", NewByteView([]byte("v1"), time.Time{}))
+	g.Set("Tom", NewByteView([]byte("v2"), time.Now().Add(-time.Second)))
+	if _, ok := g.mainCache.get("Tom"); ok {
+		t.Fatal("expired set should remove the old value")
+	}
+	if _, ok := g.hotCache.get("Tom"); ok {
+		t.Fatal("expired set should remove the hot copy")
+	}
+	if view, err := g.Get("Tom"); err != nil || view.String() != "db" {
+		t.Fatalf("expect reload after expired set, got %s", view.String())
+	}
+}
+
+// 测试LRU-K策略下Set的值直接进入缓存 不需要被加载K次
+func TestGroup_SetLRUK(t *testing.T) {
+	loads := 0
+	g := NewGroup("setlruk", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		loads++
+		return NewByteView([]byte("db"), time.Time{}), nil
+	}))
+	g.SetPolicy(LRUKPolicy(2, 0))
+
+	if err := g.Set("Tom", NewByteView([]byte("fresh"), time.Time{})); err != nil {
+		t.Fatalf("set key Tom failed: %v", err)
+	}
+	if view, err := g.Get("Tom"); err != nil || view.String() != "fresh" || loads != 0 {
+		t.Fatalf("expect fresh from cache, got %s, loads=%d", view.String(), loads)
+	}
+}
+
+// 测试ctx传递给getter
+func TestGroup_GetContext(t *testing.T) {
+	g := NewGroup("context", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		if err := ctx.Err(); err != nil {
+			return ByteView{}, err
+		}
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.GetContext(ctx, "Tom"); err != context.Canceled {
+		t.Fatalf("expect context canceled, got %v", err)
+	}
+	if view, err := g.GetContext(context.Background(), "Tom"); err != nil || view.String() != "Tom" {
+		t.Fatalf("failed to get value of key Tom: %v", err)
+	}
+}
+
+// 测试首个调用者取消不会使等待同一次加载的其他调用者失败
+func TestGroup_GetContextCancelFirst(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	g := NewGroup("cancelfirst", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		atomic.AddInt32(&loads, 1)
+		select {
+		case <-release:
+			return NewByteView([]byte(key), time.Time{}), nil
+		case <-ctx.Done():
+			return ByteView{}, ctx.Err()
+		}
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := g.GetContext(ctx, "Tom")
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	second := make(chan error)
+	go func() {
+		view, err := g.GetContext(context.Background(), "Tom")
+		if err == nil && view.String() != "Tom" {
+			err = fmt.Errorf("unexpected value %s", view)
+		}
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("expect context canceled, got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("waiter should get value after first caller canceled: %v", err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("expect 1 load, got %d", n)
+	}
+}
+
+// 测试默认过期时间
+func TestGroup_SetTTL(t *testing.T) {
+	loads := 0
+	g := NewGroup("ttl", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		loads++
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	g.SetTTL(100*time.Millisecond, 50*time.Millisecond)
+
+	view, err := g.Get("Tom")
+	if err != nil {
+		t.Fatalf("failed to get value of key Tom: %v", err)
+	}
+	if ttl := time.Until(view.Expire()); ttl <= 0 || ttl > 150*time.Millisecond {
+		t.Fatalf("expect ttl in (0, 150ms], got %v", ttl)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, err := g.Get("Tom"); err != nil || loads != 2 {
+		t.Fatalf("expect reload after ttl, loads=%d", loads)
+	}
+}
+
+// 测试自定义淘汰算法
+func TestGroup_SetPolicy(t *testing.T) {
+	created := 0
+	g := NewGroup("policy", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	g.SetPolicy(func(maxBytes int, onEvicted lru.OnEvicted) Policy {
+		created++
+		return LRUPolicy(maxBytes, onEvicted)
+	})
+
+	if view, err := g.Get("Tom"); err != nil || view.String() != "Tom" {
+		t.Fatalf("failed to get value of key Tom: %v", err)
+	}
+	if created != 1 {
+		t.Fatalf("expect custom policy to be used, created=%d", created)
+	}
+}
+
+// 测试淘汰回调
+func TestGroup_SetEvictionHook(t *testing.T) {
+	g := NewGroup("evict", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	evicted := make(map[string]EvictReason)
+	g.SetEvictionHook(func(key string, value ByteView, reason EvictReason) {
+		evicted[key] = reason
+	})
+
+	g.Get("Tom")
+	g.Delete("Tom")
+	if reason, ok := evicted["Tom"]; !ok || reason != EvictRemoved {
+		t.Fatalf("expect Tom removed, got %v", evicted)
+	}
+}
+
+// 测试内存统计
+func TestGroup_MemoryStats(t *testing.T) {
+	g := NewGroup("memory", 0, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	g.SetMaxEntries(defaultShards)
+	for i := 0; i < 100; i++ {
+		g.Get(strconv.Itoa(i))
+	}
+	main, _ := g.MemoryStats()
+	if main.Items == 0 || main.Items > defaultShards {
+		t.Fatalf("expect items limited by max entries, got %d", main.Items)
+	}
+	if main.EstimatedBytes != main.LogicalBytes+main.Items*lru.EntryOverhead {
+		t.Fatalf("unexpected memory stats %+v", main)
+	}
+}
+
+// 测试内置的淘汰算法都遵守数量限制 数量限制小于分片数量时总数仍不超过限制
+func TestGroup_SetMaxEntries(t *testing.T) {
+	policies := map[string]PolicyFunc{
+		"lru":     LRUPolicy,
+		"lfu":     LFUPolicy,
+		"lruk":    LRUKPolicy(1, 0),
+		"tinylfu": TinyLFUPolicy,
+		"arc":     ARCPolicy,
+	}
+	for name, policy := range policies {
+		for _, n := range []int{3, 20} {
+			g := NewGroup("maxentries-"+name+strconv.Itoa(n), 1<<20, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+				return NewByteView([]byte(key), time.Time{}), nil
+			}))
+			g.SetPolicy(policy)
+			g.SetMaxEntries(n)
+			g.SetAccountOverhead(true)
+			for i := 0; i < 200; i++ {
+				g.Get(strconv.Itoa(i))
+			}
+			main, _ := g.MemoryStats()
+			if main.Items == 0 || main.Items > int64(n) {
+				t.Fatalf("%s: expect at most %d items, got %d", name, n, main.Items)
+			}
+			if main.EstimatedBytes <= main.LogicalBytes {
+				t.Fatalf("%s: expect overhead in estimated bytes %+v", name, main)
+			}
+		}
+	}
+}
+
+// 测试统计信息
+func TestGroup_Stats(t *testing.T) {
+	g := NewGroup("stats", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		if key == "unknown" {
+			return ByteView{}, fmt.Errorf("%s does not exists", key)
+		}
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	g.Get("Tom")
+	g.Get("Tom")
+	g.Get("unknown")
+	g.Set("Tom", NewByteView([]byte("630"), time.Time{}))
+	g.Delete("Tom")
+
+	stats := g.Stats()
+	if stats.Gets != 3 || stats.MainCacheHits != 1 || stats.Loads != 2 || stats.LoadsExecuted != 2 || stats.FlightDedups != 0 ||
+		stats.LocalLoads != 1 || stats.LocalLoadErrs != 1 {
+		t.Fatalf("unexpected group stats %+v", stats)
+	}
+	main := stats.MainCache
+	if main.Gets != 3 || main.Hits != 1 || main.Replacements != 1 || main.Removals != 1 || main.Items != 0 {
+		t.Fatalf("unexpected cache stats %+v", main)
+	}
+	if got := groupStatsFromPB(stats.toPB()); got != stats {
+		t.Fatalf("stats changed after conversion: %+v", got)
+	}
+}
+
+// 测试按分片汇总淘汰算法统计 可以在相同负载下比较LRU和TinyLFU的命中率
+func TestGroup_PolicyStats(t *testing.T) {
+	getter := GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	})
+	lruGroup := NewGroup("policystats-lru", 4<<10, getter)
+	tinyGroup := NewGroup("policystats-tinylfu", 4<<10, getter)
+	tinyGroup.SetPolicy(TinyLFUPolicy)
+	for _, g := range []*Group{lruGroup, tinyGroup} {
+		for i := 0; i < 2000; i++ {
+			g.Get(strconv.Itoa(i % 10))   // 热点数据
+			g.Get(strconv.Itoa(i + 1000)) // 一次性扫描
+		}
+	}
+
+	lruStats, _ := lruGroup.PolicyStats()
+	if main := lruGroup.Stats().MainCache; lruStats.Hits != main.Hits || lruStats.Hits+lruStats.Misses != main.Gets {
+		t.Fatalf("lru policy stats %+v should match cache stats %+v", lruStats, main)
+	}
+	tinyStats, _ := tinyGroup.PolicyStats()
+	if tinyStats.Hits != tinyGroup.Stats().MainCache.Hits || tinyStats.Admitted+tinyStats.Rejected == 0 {
+		t.Fatalf("unexpected tinylfu policy stats %+v", tinyStats)
+	}
+	if tinyStats.HitRatio() < lruStats.HitRatio() {
+		t.Fatalf("tinylfu hit ratio %.2f should not be lower than lru %.2f under scan", tinyStats.HitRatio(), lruStats.HitRatio())
+	}
+}
+
+// 测试日志 默认热路径不输出 开启Debug后输出结构化字段
+func TestGroup_SetLogger(t *testing.T) {
+	g := NewGroup("logger", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	var buf bytes.Buffer
+	g.SetLogger(logging.NewStd(log.New(&buf, "", 0), logging.LevelInfo))
+	g.Get("Tom")
+	g.Get("Tom")
+	if buf.Len() != 0 {
+		t.Fatalf("hot path should be silent at info level, got %q", buf.String())
+	}
+	g.SetLogger(logging.NewStd(log.New(&buf, "", 0), logging.LevelDebug))
+	g.Get("Tom")
+	if out := buf.String(); !strings.Contains(out, "msg=\"main cache hit\" group=logger key_hash=") {
+		t.Fatalf("unexpected log %q", out)
+	}
+}
+
+// 测试填充热点缓存时遵守过期时间和版本号
+func TestGroup_PopulateCache(t *testing.T) {
+	g := NewGroup("populate", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	g.SetHotCache(2 << 10)
+
+	g.populateCache("expired", NewByteView([]byte("v"), time.Now().Add(-time.Second)), g.hotCache)
+	if _, ok := g.hotCache.get("expired"); ok {
+		t.Fatal("expired value should not be cached")
+	}
+
+	g.populateCache("Tom", NewByteView([]byte("new"), time.Time{}).WithMeta(2, 0), g.hotCache)
+	g.populateCache("Tom", NewByteView([]byte("old"), time.Time{}).WithMeta(1, 0), g.hotCache)
+	if v, ok := g.hotCache.get("Tom"); !ok || v.String() != "new" || v.Version() != 2 {
+		t.Fatalf("older version should not overwrite newer one, got %+v", v)
+	}
+	g.populateCache("Tom", NewByteView([]byte("newer"), time.Time{}).WithMeta(3, 0), g.hotCache)
+	if v, _ := g.hotCache.get("Tom"); v.String() != "newer" {
+		t.Fatalf("newer version should overwrite, got %s", v)
+	}
+}
+
+// 测试调用者的deadline传递给加载
+func TestGroup_GetContextDeadline(t *testing.T) {
+	var got time.Time
+	g := NewGroup("deadline", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		got, _ = ctx.Deadline()
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("deadline")
+
+	deadline := time.Now().Add(30 * time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if _, err := g.GetContext(ctx, "Tom"); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(deadline) {
+		t.Fatalf("expect deadline %v, got %v", deadline, got)
+	}
+}
+
+// fakeFetcher 记录请求的Fetcher
+type fakeFetcher struct {
+	name     string
+	fetches  int
+	replicas int // 标记为读副本请求的次数
+}
+
+func (f *fakeFetcher) Fetch(ctx context.Context, group string, key string) (ByteView, error) {
+	f.fetches++
+	if isReplicaRead(ctx) {
+		f.replicas++
+	}
+	if key == "fail" && f.name == "replica" {
+		return ByteView{}, fmt.Errorf("replica unavailable")
+	}
+	return NewByteView([]byte(f.name), time.Time{}), nil
+}
+
+func (f *fakeFetcher) Set(ctx context.Context, group string, key string, value ByteView) error {
+	return nil
+}
+
+func (f *fakeFetcher) Delete(ctx context.Context, group string, key string) error {
+	return nil
+}
+
+// fakePicker key总是归属于owner 读副本为replica
+type fakePicker struct {
+	owner, replica *fakeFetcher
+}
+
+func (p *fakePicker) Pick(key string) (Fetcher, bool) {
+	return p.owner, true
+}
+
+func (p *fakePicker) PickReplica(key string) (Fetcher, bool) {
+	return p.replica, true
+}
+
+// 测试读请求先发往本可用区的读副本 读副本收到的请求直接访问归属节点
+func TestGroup_LoadFromReplica(t *testing.T) {
+	g := NewGroup("replica", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return ByteView{}, fmt.Errorf("should load from peers")
+	}))
+	g.SetLogger(logging.Nop())
+	p := &fakePicker{owner: &fakeFetcher{name: "owner"}, replica: &fakeFetcher{name: "replica"}}
+	g.RegisterSvr(p)
+	g.SetHotCache(2 << 10)
+
+	if view, err := g.Get("Tom"); err != nil || view.String() != "replica" {
+		t.Fatalf("expect value from replica, got %v %v", view, err)
+	}
+	if p.replica.fetches != 1 || p.replica.replicas != 1 || p.owner.fetches != 0 {
+		t.Fatalf("expect 1 replica read, got replica %+v owner %+v", p.replica, p.owner)
+	}
+	// 读副本不可用时访问归属节点
+	if view, err := g.Get("fail"); err != nil || view.String() != "owner" {
+		t.Fatalf("expect value from owner, got %v %v", view, err)
+	}
+	// 读副本收到的请求不再转发给其他读副本
+	if view, err := g.GetContext(withReplicaRead(context.Background()), "Jack"); err != nil || view.String() != "owner" {
+		t.Fatalf("expect value from owner, got %v %v", view, err)
+	}
+	if p.replica.fetches != 2 || p.owner.fetches != 2 {
+		t.Fatalf("unexpected fetches, replica %+v owner %+v", p.replica, p.owner)
+	}
+}
@@ -0,0 +1,328 @@
+package lfu
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/juguagua/gCache/lru"
+	"github.com/juguagua/gCache/zset"
+)
+
+// LFU：最不经常使用。根据访问次数来决定是否被淘汰，访问次数相同时淘汰最久未访问的数据。
+// 实现采用O(1)的频次桶结构：频次桶按访问次数从小到大组成双向链表，每个桶内的节点也组成双向链表，
+// 这样获取、添加、淘汰都只需要移动常数个节点。
+// 为了避免某段时间很热的key因为积累的访问次数过大而无法被淘汰，可以开启计数衰减，
+// 每经过一定次数的访问，将所有key的访问次数减半。
+
+// Warning: lfu包不提供并发一致机制
+
+const (
+	expiresZSetKey = ""
+	// 每次移除过期键数量
+	removeExpireN = 10
+)
+
+// Cache LFU缓存
+type Cache struct {
+	capacity        int        // 缓存容量
+	length          int        // 当前key和value的字节数
+	maxEntries      int        // 最大数据数量 为0时不限制
+	accountOverhead bool       // 是否将每个entry的额外开销计入缓存大小
+	buckets         *list.List // 频次桶链表，按访问次数升序排列
+	cache           map[string]*list.Element
+	onEvicted       lru.OnEvicted   // 可选，在entry被移除的时候执行
+	expires         *zset.SortedSet // 过期键集合
+	agingPeriod     int             // 每多少次访问衰减一次访问次数，为0表示不衰减
+	accesses        int             // 距离上次衰减的访问次数
+}
+
+// bucket 频次桶，保存访问次数相同的节点，链表头部为最久未访问的节点
+type bucket struct {
+	freq    int
+	entries *list.List
+}
+
+// entry 定义双向链表节点所存储的对象
+type entry struct {
+	key    string
+	value  lru.Value
+	bucket *list.Element // 所属的频次桶
+}
+
+// New 创建指定最大容量的LFU缓存。
+// 当maxBytes为0时，代表cache无内存限制，无限存放
+func New(maxBytes int, onEvicted lru.OnEvicted) *Cache {
+	return &Cache{
+		capacity:  maxBytes,
+		buckets:   list.New(),
+		cache:     make(map[string]*list.Element),
+		onEvicted: onEvicted,
+		expires:   zset.New(),
+	}
+}
+
+// SetAging 开启访问次数衰减，每经过period次访问，所有key的访问次数减半
+// 衰减的代价为O(n)，因此period应不小于缓存的数据数量，使均摊代价为O(1)
+// period为0表示关闭衰减
+func (c *Cache) SetAging(period int) {
+	c.agingPeriod = period
+}
+
+// SetCapacity 调整缓存容量，容量变小时立即淘汰访问次数最少的数据，为0时不限制
+func (c *Cache) SetCapacity(maxBytes int) {
+	c.capacity = maxBytes
+	for c.overflow(0) {
+		c.removeLeast()
+	}
+}
+
+// SetMaxEntries 设置最大数据数量，超出后淘汰访问次数最少的数据，为0时不限制
+func (c *Cache) SetMaxEntries(n int) {
+	c.maxEntries = n
+	for c.overflow(0) {
+		c.removeLeast()
+	}
+}
+
+// SetAccountOverhead 设置是否将每个entry的估算额外开销计入缓存大小
+// 开启后容量限制更接近实际的堆内存占用
+func (c *Cache) SetAccountOverhead(account bool) {
+	c.accountOverhead = account
+	for c.overflow(0) {
+		c.removeLeast()
+	}
+}
+
+// 再增加一个extra字节的数据后是否超出容量或数量限制，extra为0时检查当前是否超出
+func (c *Cache) overflow(extra int) bool {
+	if c.Len() == 0 {
+		return false
+	}
+	entries := c.Len()
+	if extra != 0 {
+		entries++
+	}
+	return c.capacity != 0 && c.Bytes()+extra > c.capacity || c.maxEntries != 0 && entries > c.maxEntries
+}
+
+// Get 从缓存获取对应key的value
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	element, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	ent := element.Value.(*entry)
+	// 移除过期的键
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		c.removeElement(element, lru.EvictExpired)
+		return nil, false
+	}
+	c.increment(element)
+	c.access()
+	return ent.value, true
+}
+
+// Peek 获取对应key的value 不增加访问次数 已过期的value视为不存在但不会被移除
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	element, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	ent := element.Value.(*entry)
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		return nil, false
+	}
+	return ent.value, true
+}
+
+// Add 添加数据到缓存
+func (c *Cache) Add(key string, value lru.Value) {
+	if element, ok := c.cache[key]; ok {
+		ent := element.Value.(*entry)
+		c.length += value.Len() - ent.value.Len()
+		old := ent.value
+		ent.value = value
+		c.increment(element)
+		if c.onEvicted != nil {
+			c.onEvicted(key, old, lru.EvictReplaced)
+		}
+	} else {
+		// 淘汰过期的key
+		if c.capacity != 0 {
+			c.removeExpire(removeExpireN)
+		}
+		// 先淘汰再插入，否则新插入的key访问次数最少，会被立即淘汰
+		for c.overflow(c.size(key, value)) {
+			c.removeLeast()
+		}
+		c.insert(key, value)
+	}
+	// 如果有超时时间则设置
+	if !value.Expire().IsZero() {
+		c.expires.ZAdd(expiresZSetKey, value.Expire().UnixNano(), key)
+	} else {
+		// 没有则删除
+		c.expires.ZRem(expiresZSetKey, key)
+	}
+	// 单个数据超过容量时，仍然需要保证不超出容量
+	for c.overflow(0) {
+		c.removeLeast()
+	}
+	c.access()
+}
+
+// Remove 移除某个键
+func (c *Cache) Remove(key string) {
+	if element, ok := c.cache[key]; ok {
+		c.removeElement(element, lru.EvictRemoved)
+	}
+}
+
+// Len 返回数据数量
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 返回当前缓存占用的字节数，开启额外开销统计时包含估算的额外开销
+func (c *Cache) Bytes() int {
+	if c.accountOverhead {
+		return c.EstimatedBytes()
+	}
+	return c.length
+}
+
+// LogicalBytes 返回key和value的字节数
+func (c *Cache) LogicalBytes() int {
+	return c.length
+}
+
+// EstimatedBytes 返回包含频次桶节点、map、过期集合等额外开销的估算字节数
+func (c *Cache) EstimatedBytes() int {
+	return c.length + c.Len()*lru.EntryOverhead + c.expires.ZCard(expiresZSetKey)*lru.ExpireOverhead
+}
+
+// 新数据计入缓存大小的字节数
+func (c *Cache) size(key string, value lru.Value) int {
+	if c.accountOverhead {
+		return len(key) + value.Len() + lru.Overhead(value)
+	}
+	return len(key) + value.Len()
+}
+
+// 插入访问次数为1的新节点
+func (c *Cache) insert(key string, value lru.Value) {
+	front := c.buckets.Front()
+	if front == nil || front.Value.(*bucket).freq != 1 {
+		front = c.buckets.PushFront(&bucket{freq: 1, entries: list.New()})
+	}
+	ent := &entry{key: key, value: value, bucket: front}
+	c.cache[key] = front.Value.(*bucket).entries.PushBack(ent)
+	c.length += len(key) + value.Len()
+}
+
+// 将节点的访问次数加一，移动到下一个频次桶的尾部
+func (c *Cache) increment(e *list.Element) {
+	ent := e.Value.(*entry)
+	cur := ent.bucket
+	freq := cur.Value.(*bucket).freq + 1
+	next := cur.Next()
+	if next == nil || next.Value.(*bucket).freq != freq {
+		next = c.buckets.InsertAfter(&bucket{freq: freq, entries: list.New()}, cur)
+	}
+	c.detach(e)
+	ent.bucket = next
+	c.cache[ent.key] = next.Value.(*bucket).entries.PushBack(ent)
+}
+
+// 将节点从所属频次桶中摘除，桶为空时删除该桶
+func (c *Cache) detach(e *list.Element) {
+	ent := e.Value.(*entry)
+	b := ent.bucket.Value.(*bucket)
+	b.entries.Remove(e)
+	if b.entries.Len() == 0 {
+		c.buckets.Remove(ent.bucket)
+	}
+}
+
+// 记录一次访问，达到衰减周期则进行衰减
+func (c *Cache) access() {
+	if c.agingPeriod == 0 {
+		return
+	}
+	c.accesses++
+	if c.accesses >= c.agingPeriod {
+		c.accesses = 0
+		c.decay()
+	}
+}
+
+// 将所有key的访问次数减半(至少为1)，同一频次内保持原有的访问先后顺序
+func (c *Cache) decay() {
+	old := c.buckets
+	c.buckets = list.New()
+	for be := old.Front(); be != nil; be = be.Next() {
+		b := be.Value.(*bucket)
+		freq := b.freq / 2
+		if freq < 1 {
+			freq = 1
+		}
+		// 原频次升序，减半后仍然有序，只需要与最后一个桶比较
+		back := c.buckets.Back()
+		if back == nil || back.Value.(*bucket).freq != freq {
+			back = c.buckets.PushBack(&bucket{freq: freq, entries: list.New()})
+		}
+		entries := back.Value.(*bucket).entries
+		for e := b.entries.Front(); e != nil; e = e.Next() {
+			ent := e.Value.(*entry)
+			ent.bucket = back
+			c.cache[ent.key] = entries.PushBack(ent)
+		}
+	}
+}
+
+// 移除访问次数最少的数据，次数相同时移除最久未访问的数据
+func (c *Cache) removeLeast() {
+	front := c.buckets.Front()
+	if front != nil {
+		c.removeElement(front.Value.(*bucket).entries.Front(), lru.EvictCapacity)
+	}
+}
+
+// 移除指定键，并删除链表里面的节点，减少lfu缓存大小，删除过期时间，调用回调函数
+func (c *Cache) removeElement(e *list.Element, reason lru.EvictReason) {
+	c.detach(e)
+	ent := e.Value.(*entry)
+	delete(c.cache, ent.key)
+	c.length -= len(ent.key) + ent.value.Len()
+	// 移除过期键
+	if !ent.value.Expire().IsZero() {
+		c.expires.ZRem(expiresZSetKey, ent.key)
+	}
+	if c.onEvicted != nil {
+		c.onEvicted(ent.key, ent.value, reason)
+	}
+}
+
+// RemoveExpired 主动移除最多n个已过期的键
+// 返回实际移除的数量
+func (c *Cache) RemoveExpired(n int) int {
+	return n - c.removeExpire(n)
+}
+
+// 移除过期的键
+// 返回未删除的数量
+func (c *Cache) removeExpire(n int) int {
+	for n > 0 && c.expires.ZCard(expiresZSetKey) > 0 {
+		values := c.expires.ZRangeWithScores(expiresZSetKey, 0, 0)
+		key, expireNano := values[0].(string), values[1].(int64)
+		// 第一个键都没超时，结束循环
+		if expireNano > time.Now().UnixNano() {
+			break
+		}
+		if element, ok := c.cache[key]; ok {
+			c.removeElement(element, lru.EvictExpired)
+		}
+		n--
+	}
+	return n
+}
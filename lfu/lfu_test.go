@@ -0,0 +1,165 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juguagua/gCache/lru"
+)
+
+type String struct {
+	s      string
+	expire time.Time
+}
+
+func (s *String) Len() int {
+	return len(s.s)
+}
+
+func (s *String) Expire() time.Time {
+	return s.expire
+}
+
+func TestCache_Get(t *testing.T) {
+	lfu := New(0, nil)
+	testKey, testValue := "key1", &String{s: "value1"}
+	lfu.Add(testKey, testValue)
+	if value, ok := lfu.Get(testKey); !ok || value.(*String) != testValue {
+		t.Fatalf("cache hit %v:%v failed\n", testKey, testValue)
+	}
+	notCacheKey := "key2"
+	if _, ok := lfu.Get(notCacheKey); ok {
+		t.Fatalf("hit not cache key=%s\n", notCacheKey)
+	}
+}
+
+func TestCache_RemoveLeast(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "key3"
+	v1, v2, v3 := &String{s: "value1"}, &String{s: "value2"}, &String{s: "value3"}
+	capacity := len(k1) + len(k2) + v1.Len() + v2.Len()
+	lfu := New(capacity, nil)
+	lfu.Add(k1, v1)
+	lfu.Add(k2, v2)
+	// k1访问次数更多，应淘汰k2
+	lfu.Get(k1)
+	lfu.Add(k3, v3)
+
+	if _, ok := lfu.Get(k2); ok || lfu.Len() != 2 {
+		t.Fatalf("remove least %v:%v failed, len=%d\n", k2, v2, lfu.Len())
+	}
+	if _, ok := lfu.Get(k1); !ok {
+		t.Fatalf("frequent key %v:%v should not be removed\n", k1, v1)
+	}
+	if lfu.Bytes() != capacity {
+		t.Fatalf("expect bytes %d, got %d\n", capacity, lfu.Bytes())
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	k1, k2 := "key1", "key2"
+	v1, v2 := &String{s: "value1"}, &String{s: "value2"}
+	lfu := New(0, nil)
+	lfu.Add(k1, v1)
+	lfu.Add(k2, v2)
+	lfu.Get(k1)
+	lfu.Remove(k1)
+	if _, ok := lfu.Get(k1); ok || lfu.Len() != 1 {
+		t.Fatalf("remove key %v:%v failed, len=%d\n", k1, v1, lfu.Len())
+	}
+	if val, ok := lfu.Get(k2); !ok || val != v2 {
+		t.Fatalf("get key %v:%v failed, len=%d\n", k2, v2, lfu.Len())
+	}
+}
+
+func TestCache_OnEvicted(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "key3"
+	v1, v2, v3 := &String{s: "value1"}, &String{s: "value2"}, &String{s: "value3"}
+	capacity := len(k1) + len(k2) + v1.Len() + v2.Len()
+	var evictedKey string
+	lfu := New(capacity, func(key string, value lru.Value, reason lru.EvictReason) {
+		evictedKey = key
+	})
+	lfu.Add(k1, v1)
+	lfu.Add(k2, v2)
+	lfu.Add(k3, v3)
+	if evictedKey != k1 {
+		t.Fatalf("evicted failed; evicted key = %v\n", evictedKey)
+	}
+}
+
+func TestCache_Aging(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "key3"
+	v1, v2, v3 := &String{s: "value1"}, &String{s: "value2"}, &String{s: "value3"}
+	capacity := len(k1) + len(k2) + v1.Len() + v2.Len()
+	lfu := New(capacity, nil)
+	lfu.SetAging(4)
+	lfu.Add(k1, v1)
+	for i := 0; i < 3; i++ { // 第4次访问触发衰减 k1: 4 -> 2
+		lfu.Get(k1)
+	}
+	lfu.Add(k2, v2)
+	for i := 0; i < 3; i++ { // 第4次访问触发衰减 k1: 2 -> 1, k2: 4 -> 2
+		lfu.Get(k2)
+	}
+	lfu.Add(k3, v3)
+
+	if _, ok := lfu.Get(k1); ok {
+		t.Fatalf("previously hot key %v should be removed after aging\n", k1)
+	}
+	if _, ok := lfu.Get(k2); !ok {
+		t.Fatalf("get key %v failed\n", k2)
+	}
+}
+
+func TestCache_Expire(t *testing.T) {
+	k1, k2 := "key1", "key2"
+	v1, v2 := &String{s: "value1"}, &String{s: "value2", expire: time.Now().Add(100 * time.Millisecond)}
+	lfu := New(1000, nil)
+	lfu.Add(k1, v1)
+	lfu.Add(k2, v2)
+	if _, ok := lfu.Get(k2); !ok || lfu.Len() != 2 {
+		t.Fatalf("get %v:%v failed, len=%d\n", k2, v2, lfu.Len())
+	}
+	time.Sleep(100 * time.Millisecond)
+	lfu.removeExpire(removeExpireN)
+	if _, ok := lfu.Get(k2); ok || lfu.Len() != 1 {
+		t.Fatalf("expire %v:%v failed, len=%d\n", k2, v2, lfu.Len())
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	lfu := New(0, nil)
+	lfu.SetMaxEntries(2)
+	lfu.Add("key1", &String{s: "value1"})
+	lfu.Get("key1")
+	lfu.Add("key2", &String{s: "value2"})
+	lfu.Add("key3", &String{s: "value3"})
+	if _, ok := lfu.Get("key2"); ok || lfu.Len() != 2 {
+		t.Fatalf("max entries failed, len=%d\n", lfu.Len())
+	}
+	if _, ok := lfu.Get("key1"); !ok {
+		t.Fatalf("frequently used key1 should be kept\n")
+	}
+}
+
+func TestCache_AccountOverhead(t *testing.T) {
+	k1, v1 := "key1", &String{s: "value1", expire: time.Now().Add(time.Hour)}
+	lfu := New(0, nil)
+	lfu.Add(k1, v1)
+	logical := len(k1) + v1.Len()
+	if lfu.Bytes() != logical || lfu.LogicalBytes() != logical {
+		t.Fatalf("expect %d logical bytes, got %d\n", logical, lfu.Bytes())
+	}
+	if estimated := logical + lru.EntryOverhead + lru.ExpireOverhead; lfu.EstimatedBytes() != estimated {
+		t.Fatalf("expect %d estimated bytes, got %d\n", estimated, lfu.EstimatedBytes())
+	}
+
+	// 计入额外开销后 容量只够存放一个entry
+	lfu = New(2*(logical+lru.EntryOverhead)-1, nil)
+	lfu.SetAccountOverhead(true)
+	lfu.Add("key1", &String{s: "value1"})
+	lfu.Add("key2", &String{s: "value2"})
+	if lfu.Len() != 1 {
+		t.Fatalf("expect 1 entry with overhead accounted, len=%d\n", lfu.Len())
+	}
+}
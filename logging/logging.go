@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logging 提供带级别的结构化日志接口
+// 热路径上的日志使用LevelDebug 默认的logger只输出LevelInfo及以上 因此热路径默认静默
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Field 结构化日志的字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F 创建一个字段
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Group 缓存命名空间字段
+func Group(name string) Field {
+	return Field{Key: "group", Value: name}
+}
+
+// KeyHash key的哈希字段 避免在日志中输出原始key
+func KeyHash(key string) Field {
+	return Field{Key: "key_hash", Value: HashKey(key)}
+}
+
+// HashKey 返回key的FNV-1a哈希的十六进制表示
+func HashKey(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Peer 远程节点字段
+func Peer(addr string) Field {
+	return Field{Key: "peer", Value: addr}
+}
+
+// Latency 耗时字段
+func Latency(d time.Duration) Field {
+	return Field{Key: "latency", Value: d}
+}
+
+// Err 错误字段
+func Err(err error) Field {
+	return Field{Key: "err", Value: err}
+}
+
+// Logger 带级别的结构化日志接口
+// 调用方在构造字段的开销不可忽略时 应先调用Enabled判断
+type Logger interface {
+	Enabled(level Level) bool
+	Log(level Level, msg string, fields ...Field)
+}
+
+// Debug 输出LevelDebug日志
+func Debug(l Logger, msg string, fields ...Field) {
+	l.Log(LevelDebug, msg, fields...)
+}
+
+// Info 输出LevelInfo日志
+func Info(l Logger, msg string, fields ...Field) {
+	l.Log(LevelInfo, msg, fields...)
+}
+
+// Warn 输出LevelWarn日志
+func Warn(l Logger, msg string, fields ...Field) {
+	l.Log(LevelWarn, msg, fields...)
+}
+
+// Error 输出LevelError日志
+func Error(l Logger, msg string, fields ...Field) {
+	l.Log(LevelError, msg, fields...)
+}
+
+type nop struct{}
+
+func (nop) Enabled(Level) bool          { return false }
+func (nop) Log(Level, string, ...Field) {}
+
+// Nop 返回丢弃所有日志的Logger
+func Nop() Logger {
+	return nop{}
+}
+
+// std 基于标准库log的Logger 输出形如 level=INFO msg="..." key=value
+type std struct {
+	l     *log.Logger
+	level Level
+}
+
+// NewStd 创建基于标准库log的Logger 低于level的日志将被丢弃
+// l为nil时输出到标准错误
+func NewStd(l *log.Logger, level Level) Logger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &std{l: l, level: level}
+}
+
+var defaultLogger = NewStd(nil, LevelInfo)
+
+// Default 返回默认的Logger 输出LevelInfo及以上的日志到标准错误
+func Default() Logger {
+	return defaultLogger
+}
+
+func (s *std) Enabled(level Level) bool {
+	return level >= s.level
+}
+
+func (s *std) Log(level Level, msg string, fields ...Field) {
+	if !s.Enabled(level) {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("level=")
+	b.WriteString(level.String())
+	b.WriteString(" msg=")
+	b.WriteString(quote(msg))
+	for _, f := range fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(quote(fmt.Sprint(f.Value)))
+	}
+	s.l.Output(2, b.String())
+}
+
+// quote 包含空白或引号时加上引号
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// with 为每条日志附加固定字段的Logger
+type with struct {
+	l      Logger
+	fields []Field
+}
+
+// With 返回为每条日志附加fields的Logger
+func With(l Logger, fields ...Field) Logger {
+	if len(fields) == 0 {
+		return l
+	}
+	if w, ok := l.(*with); ok {
+		return &with{l: w.l, fields: append(append([]Field(nil), w.fields...), fields...)}
+	}
+	return &with{l: l, fields: fields}
+}
+
+func (w *with) Enabled(level Level) bool {
+	return w.l.Enabled(level)
+}
+
+func (w *with) Log(level Level, msg string, fields ...Field) {
+	if !w.l.Enabled(level) {
+		return
+	}
+	w.l.Log(level, msg, append(append([]Field(nil), w.fields...), fields...)...)
+}
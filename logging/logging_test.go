@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStd(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStd(log.New(&buf, "", 0), LevelInfo)
+	Debug(l, "hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("debug log should be dropped, got %q", buf.String())
+	}
+	l = With(l, Group("scores"))
+	Warn(l, "peer failed", KeyHash("Tom"), Peer("127.0.0.1:6324"), Err(errors.New("timeout")))
+	expect := `level=WARN msg="peer failed" group=scores key_hash=` + KeyHash("Tom").Value.(string) +
+		" peer=127.0.0.1:6324 err=timeout\n"
+	if buf.String() != expect {
+		t.Fatalf("expect %q, got %q", expect, buf.String())
+	}
+	if strings.Contains(buf.String(), "Tom") {
+		t.Fatal("raw key should not be logged")
+	}
+}
+
+func TestNop(t *testing.T) {
+	l := Nop()
+	if l.Enabled(LevelError) {
+		t.Fatal("nop logger should be disabled")
+	}
+	Error(l, "ignored")
+}
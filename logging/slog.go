@@ -0,0 +1,37 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogLogger 将日志转发给log/slog
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlog 创建转发给slog.Logger的Logger 日志级别映射到slog的同名级别
+func NewSlog(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &slogLogger{l: l}
+}
+
+func (s *slogLogger) Enabled(level Level) bool {
+	return s.l.Enabled(context.Background(), slog.Level(level*4))
+}
+
+func (s *slogLogger) Log(level Level, msg string, fields ...Field) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, slog.Level(level*4)) {
+		return
+	}
+	attrs := make([]slog.Attr, len(fields))
+	for i, f := range fields {
+		attrs[i] = slog.Any(f.Key, f.Value)
+	}
+	s.l.LogAttrs(ctx, slog.Level(level*4), msg, attrs...)
+}
@@ -0,0 +1,29 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	if l.Enabled(LevelDebug) {
+		t.Fatal("debug should be disabled by default handler options")
+	}
+	Warn(l, "peer failed", Peer("127.0.0.1:6324"))
+	expect := "level=WARN msg=\"peer failed\" peer=127.0.0.1:6324\n"
+	if buf.String() != expect {
+		t.Fatalf("expect %q, got %q", expect, buf.String())
+	}
+}
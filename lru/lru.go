@@ -11,7 +11,6 @@ import (
 // LFU：最不经常使用。它根据访问次数来决定是否被淘汰，可能会存在某个一段时间很热的key在另外一段时间不那么热，却由于积累的访问次数过大而无法被淘汰。它的实现使用两个map+双向链表。https://juejin.cn/post/6987260805888606245#heading-2
 
 // Warning: lru包不提供并发一致机制
-// TODO: 实现lru-k
 
 const (
 	expiresZSetKey = ""
@@ -19,14 +18,62 @@ const (
 	removeExpireN = 10
 )
 
+// 64位平台下每个entry除key和value本身之外的估算内存开销
+const (
+	// EntryOverhead 每个entry的开销：
+	// list.Element(48) + Entry(32) + 装箱到Value接口的ByteView(48) + map中的key、指针及负载因子损耗(40)
+	EntryOverhead = 168
+	// ExpireOverhead 有过期时间的entry在过期集合中的额外开销：
+	// 跳表节点(64) + 平均1.33层的层级指针(32) + 字典中的member和指针(40)
+	ExpireOverhead = 136
+)
+
+// Overhead 返回value对应entry除key和value本身之外的估算内存开销，有过期时间时包含过期集合的开销
+func Overhead(value Value) int {
+	if value.Expire().IsZero() {
+		return EntryOverhead
+	}
+	return EntryOverhead + ExpireOverhead
+}
+
+// EvictReason 数据被移除的原因
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // 超出容量被淘汰
+	EvictExpired                     // 过期被移除
+	EvictRemoved                     // 被主动删除
+	EvictReplaced                    // 被新的value覆盖
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// OnEvicted 在entry被移除的时候执行的回调函数，reason为移除的原因
+// 被覆盖时value为旧的value
+type OnEvicted func(key string, value Value, reason EvictReason)
+
 // Cache LRU缓存
 type Cache struct {
-	capacity  int // 缓存容量
-	length    int // 当前缓存大小
-	ll        *list.List
-	cache     map[string]*list.Element
-	onEvicted func(key string, value Value) // 可选，在entry被移除的时候执行
-	expires   *zset.SortedSet               // 过期键集合
+	capacity        int  // 缓存容量
+	length          int  // 当前key和value的字节数
+	maxEntries      int  // 最大数据数量 为0时不限制
+	accountOverhead bool // 是否将每个entry的额外开销计入缓存大小
+	ll              *list.List
+	cache           map[string]*list.Element
+	onEvicted       OnEvicted       // 可选，在entry被移除的时候执行
+	expires         *zset.SortedSet // 过期键集合
 }
 
 // Entry 定义双向链表节点所存储的对象
@@ -43,7 +90,7 @@ type Value interface {
 
 // New 创建指定最大容量的LRU缓存。
 // 当maxBytes为0时，代表cache无内存限制，无限存放
-func New(maxBytes int, onEvicted func(key string, value Value)) *Cache {
+func New(maxBytes int, onEvicted OnEvicted) *Cache {
 	return &Cache{
 		capacity:  maxBytes,
 		ll:        list.New(),
@@ -62,20 +109,37 @@ func (c *Cache) Get(key string) (value Value, ok bool) {
 	ent := element.Value.(*Entry)
 	// 移除过期的键
 	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
-		c.removeElement(element)
+		c.removeElement(element, EvictExpired)
 		return nil, false
 	}
 	c.ll.MoveToBack(element)
 	return ent.value, true
 }
 
+// Peek 获取对应key的value 不改变访问顺序 已过期的value视为不存在但不会被移除
+func (c *Cache) Peek(key string) (value Value, ok bool) {
+	element, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	ent := element.Value.(*Entry)
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		return nil, false
+	}
+	return ent.value, true
+}
+
 // Add 添加数据到缓存
 func (c *Cache) Add(key string, value Value) {
 	if element, ok := c.cache[key]; ok {
 		c.ll.MoveToBack(element)
 		ent := element.Value.(*Entry)
 		c.length += value.Len() - ent.value.Len()
+		old := ent.value
 		ent.value = value
+		if c.onEvicted != nil {
+			c.onEvicted(key, old, EvictReplaced)
+		}
 	} else {
 		ent := &Entry{
 			key:   key,
@@ -98,15 +162,48 @@ func (c *Cache) Add(key string, value Value) {
 	}
 
 	// 淘汰最近最少访问的key
-	for c.capacity != 0 && c.length > c.capacity {
+	for c.overflow() {
 		c.removeOldest()
 	}
 }
 
+// SetCapacity 调整缓存容量，容量变小时立即淘汰最近最少访问的数据，为0时不限制
+func (c *Cache) SetCapacity(maxBytes int) {
+	c.capacity = maxBytes
+	for c.overflow() {
+		c.removeOldest()
+	}
+}
+
+// SetMaxEntries 设置最大数据数量，超出后淘汰最近最少访问的数据，为0时不限制
+func (c *Cache) SetMaxEntries(n int) {
+	c.maxEntries = n
+	for c.overflow() {
+		c.removeOldest()
+	}
+}
+
+// SetAccountOverhead 设置是否将每个entry的估算额外开销计入缓存大小
+// 开启后容量限制更接近实际的堆内存占用
+func (c *Cache) SetAccountOverhead(account bool) {
+	c.accountOverhead = account
+	for c.overflow() {
+		c.removeOldest()
+	}
+}
+
+// 是否超出容量或数量限制
+func (c *Cache) overflow() bool {
+	if c.Len() == 0 {
+		return false
+	}
+	return c.capacity != 0 && c.Bytes() > c.capacity || c.maxEntries != 0 && c.Len() > c.maxEntries
+}
+
 // Remove 移除某个键
 func (c *Cache) Remove(key string) {
 	if element, ok := c.cache[key]; ok {
-		c.removeElement(element)
+		c.removeElement(element, EvictRemoved)
 	}
 }
 
@@ -115,16 +212,34 @@ func (c *Cache) Len() int {
 	return c.ll.Len()
 }
 
+// Bytes 返回当前缓存占用的字节数，开启额外开销统计时包含估算的额外开销
+func (c *Cache) Bytes() int {
+	if c.accountOverhead {
+		return c.EstimatedBytes()
+	}
+	return c.length
+}
+
+// LogicalBytes 返回key和value的字节数
+func (c *Cache) LogicalBytes() int {
+	return c.length
+}
+
+// EstimatedBytes 返回包含链表节点、map、过期集合等额外开销的估算字节数
+func (c *Cache) EstimatedBytes() int {
+	return c.length + c.Len()*EntryOverhead + c.expires.ZCard(expiresZSetKey)*ExpireOverhead
+}
+
 // 移除最近最少访问的数据
 func (c *Cache) removeOldest() {
 	front := c.ll.Front()
 	if front != nil {
-		c.removeElement(front)
+		c.removeElement(front, EvictCapacity)
 	}
 }
 
 // 移除指定键，并删除链表里面的节点，减少lru缓存大小，删除过期时间，调用回调函数
-func (c *Cache) removeElement(e *list.Element) {
+func (c *Cache) removeElement(e *list.Element, reason EvictReason) {
 	c.ll.Remove(e)
 	kv := e.Value.(*Entry)
 	delete(c.cache, kv.key)
@@ -134,10 +249,16 @@ func (c *Cache) removeElement(e *list.Element) {
 		c.expires.ZRem(expiresZSetKey, kv.key)
 	}
 	if c.onEvicted != nil {
-		c.onEvicted(kv.key, kv.value)
+		c.onEvicted(kv.key, kv.value, reason)
 	}
 }
 
+// RemoveExpired 主动移除最多n个已过期的键
+// 返回实际移除的数量
+func (c *Cache) RemoveExpired(n int) int {
+	return n - c.removeExpire(n)
+}
+
 // 移除过期的键
 // 返回未删除的数量
 func (c *Cache) removeExpire(n int) int {
@@ -148,7 +269,9 @@ func (c *Cache) removeExpire(n int) int {
 		if expireNano > time.Now().UnixNano() {
 			break
 		}
-		c.Remove(key)
+		if element, ok := c.cache[key]; ok {
+			c.removeElement(element, EvictExpired)
+		}
 		n--
 	}
 	return n
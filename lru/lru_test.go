@@ -75,15 +75,37 @@ func TestCache_OnEvicted(t *testing.T) {
 	capacity := len(k1) + len(k2) + v1.Len() + v2.Len()
 	var evictedKey string
 	var evictedValue Value
-	lru := New(capacity, func(key string, value Value) {
+	var evictedReason EvictReason
+	lru := New(capacity, func(key string, value Value, reason EvictReason) {
 		evictedKey = key
 		evictedValue = value
+		evictedReason = reason
 	})
 	lru.Add(k1, v1)
 	lru.Add(k2, v2)
 	lru.Add(k3, v3)
-	if value, ok := evictedValue.(*String); !ok || evictedKey != k1 || value != v1 {
-		t.Fatalf("evicted failed; evicted key = %v, value = %v\n", evictedKey, value)
+	if value, ok := evictedValue.(*String); !ok || evictedKey != k1 || value != v1 || evictedReason != EvictCapacity {
+		t.Fatalf("evicted failed; evicted key = %v, value = %v, reason = %v\n", evictedKey, value, evictedReason)
+	}
+}
+
+func TestCache_EvictReason(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	lru := New(0, func(key string, value Value, reason EvictReason) {
+		reasons[key] = reason
+	})
+	lru.Add("replaced", &String{s: "value1"})
+	lru.Add("replaced", &String{s: "value2"})
+	lru.Add("removed", &String{s: "value"})
+	lru.Remove("removed")
+	lru.Add("expired", &String{s: "value", expire: time.Now().Add(-time.Second)})
+	lru.Get("expired")
+
+	expected := map[string]EvictReason{"replaced": EvictReplaced, "removed": EvictRemoved, "expired": EvictExpired}
+	for key, reason := range expected {
+		if reasons[key] != reason {
+			t.Fatalf("key %s expect reason %v, got %v\n", key, reason, reasons[key])
+		}
 	}
 }
 
@@ -130,3 +152,62 @@ func TestCache_RemoveExpire(t *testing.T) {
 		t.Fatalf("remove expire keys failed, len=%d\n", lru.Len())
 	}
 }
+
+func TestCache_RemoveExpired(t *testing.T) {
+	lru := New(0, nil)
+	lru.Add("key1", &String{s: "value1", expire: time.Now().Add(-time.Hour)})
+	lru.Add("key2", &String{s: "value2", expire: time.Now().Add(time.Hour)})
+	lru.Add("key3", &String{s: "value3"})
+	if n := lru.RemoveExpired(10); n != 1 || lru.Len() != 2 {
+		t.Fatalf("expect 1 expired key removed, got %d, len=%d\n", n, lru.Len())
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	lru := New(0, nil)
+	lru.SetMaxEntries(2)
+	lru.Add("key1", &String{s: "value1"})
+	lru.Add("key2", &String{s: "value2"})
+	lru.Add("key3", &String{s: "value3"})
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 2 {
+		t.Fatalf("max entries failed, len=%d\n", lru.Len())
+	}
+}
+
+func TestCache_AccountOverhead(t *testing.T) {
+	k1, v1 := "key1", &String{s: "value1", expire: time.Now().Add(time.Hour)}
+	lru := New(0, nil)
+	lru.Add(k1, v1)
+	logical := len(k1) + v1.Len()
+	if lru.Bytes() != logical || lru.LogicalBytes() != logical {
+		t.Fatalf("expect %d logical bytes, got %d\n", logical, lru.Bytes())
+	}
+	if estimated := logical + EntryOverhead + ExpireOverhead; lru.EstimatedBytes() != estimated {
+		t.Fatalf("expect %d estimated bytes, got %d\n", estimated, lru.EstimatedBytes())
+	}
+
+	// 计入额外开销后 容量只够存放一个entry
+	lru = New(2*(logical+EntryOverhead)-1, nil)
+	lru.SetAccountOverhead(true)
+	lru.Add("key1", &String{s: "value1"})
+	lru.Add("key2", &String{s: "value2"})
+	if lru.Len() != 1 {
+		t.Fatalf("expect 1 entry with overhead accounted, len=%d\n", lru.Len())
+	}
+}
+
+func TestCache_Peek(t *testing.T) {
+	k1, k2, k3 := "key1", "key2", "key3"
+	v1, v2, v3 := &String{s: "value1"}, &String{s: "value2"}, &String{s: "value3"}
+	lru := New(len(k1+k2)+v1.Len()+v2.Len(), nil)
+	lru.Add(k1, v1)
+	lru.Add(k2, v2)
+	// Peek不改变访问顺序 k1仍然最先被淘汰
+	if value, ok := lru.Peek(k1); !ok || value != v1 {
+		t.Fatalf("peek key %v failed\n", k1)
+	}
+	lru.Add(k3, v3)
+	if _, ok := lru.Peek(k1); ok || lru.Len() != 2 {
+		t.Fatalf("peek should not change the order, len=%d\n", lru.Len())
+	}
+}
@@ -0,0 +1,142 @@
+package lru
+
+import "container/list"
+
+// LRU-K：数据需要被访问K次才会进入缓存，用于解决批量扫描等一次性访问造成的缓存污染。
+// 未达到K次访问的key只记录在历史队列中，历史队列本身按LRU淘汰且只保存访问次数，不保存value。
+// 注意：对于通过Add填充的缓存，每次Add视为一次访问，即同一个key需要被加载K次才会被缓存。
+// 通过Set显式写入的数据直接进入缓存，写入方明确要求缓存该值，不应被当作一次性访问丢弃。
+
+// Warning: lru包不提供并发一致机制
+
+const defaultHistoryLen = 1024
+
+// KCache LRU-K缓存
+type KCache struct {
+	k          int
+	historyLen int                      // 历史队列最大长度
+	history    *list.List               // 历史队列，头部为最近访问的key
+	records    map[string]*list.Element // 历史队列中key到节点的映射
+	cache      *Cache                   // 访问次数达到K次的数据
+}
+
+// record 历史队列中保存的访问记录
+type record struct {
+	key   string
+	count int
+}
+
+// NewK 创建指定最大容量的LRU-K缓存，k通常为2。
+// historyLen为历史队列能记录的key数量，<=0时使用默认值。
+// 当maxBytes为0时，代表cache无内存限制，无限存放
+func NewK(k int, historyLen int, maxBytes int, onEvicted OnEvicted) *KCache {
+	if k < 1 {
+		panic("k must be greater than 0")
+	}
+	if historyLen <= 0 {
+		historyLen = defaultHistoryLen
+	}
+	return &KCache{
+		k:          k,
+		historyLen: historyLen,
+		history:    list.New(),
+		records:    make(map[string]*list.Element),
+		cache:      New(maxBytes, onEvicted),
+	}
+}
+
+// Get 从缓存获取对应key的value，仅在历史队列中的key视为未命中
+func (c *KCache) Get(key string) (value Value, ok bool) {
+	return c.cache.Get(key)
+}
+
+// Peek 获取对应key的value 不改变访问顺序和访问记录
+func (c *KCache) Peek(key string) (value Value, ok bool) {
+	return c.cache.Peek(key)
+}
+
+// Add 记录一次访问，访问次数达到K次时将数据加入缓存
+func (c *KCache) Add(key string, value Value) {
+	if _, ok := c.cache.cache[key]; ok || c.k == 1 {
+		c.cache.Add(key, value)
+		return
+	}
+	element, ok := c.records[key]
+	if ok {
+		c.history.MoveToFront(element)
+	} else {
+		element = c.history.PushFront(&record{key: key})
+		c.records[key] = element
+		if c.history.Len() > c.historyLen {
+			c.removeRecord(c.history.Back())
+		}
+	}
+	rec := element.Value.(*record)
+	rec.count++
+	if rec.count >= c.k {
+		c.removeRecord(element)
+		c.cache.Add(key, value)
+	}
+}
+
+// Set 显式写入数据 数据直接进入缓存并清除其访问记录
+func (c *KCache) Set(key string, value Value) {
+	if element, ok := c.records[key]; ok {
+		c.removeRecord(element)
+	}
+	c.cache.Add(key, value)
+}
+
+// Remove 移除某个键，同时清除其访问记录
+func (c *KCache) Remove(key string) {
+	if element, ok := c.records[key]; ok {
+		c.removeRecord(element)
+	}
+	c.cache.Remove(key)
+}
+
+// Len 返回缓存中的数据数量，不包含历史队列
+func (c *KCache) Len() int {
+	return c.cache.Len()
+}
+
+// Bytes 返回当前缓存占用的字节数，不包含历史队列
+func (c *KCache) Bytes() int {
+	return c.cache.Bytes()
+}
+
+// RemoveExpired 主动移除最多n个已过期的键
+// 返回实际移除的数量
+func (c *KCache) RemoveExpired(n int) int {
+	return c.cache.RemoveExpired(n)
+}
+
+// SetCapacity 调整缓存容量，为0时不限制
+func (c *KCache) SetCapacity(maxBytes int) {
+	c.cache.SetCapacity(maxBytes)
+}
+
+// SetMaxEntries 设置缓存的最大数据数量，为0时不限制
+func (c *KCache) SetMaxEntries(n int) {
+	c.cache.SetMaxEntries(n)
+}
+
+// SetAccountOverhead 设置是否将每个entry的估算额外开销计入缓存大小
+func (c *KCache) SetAccountOverhead(account bool) {
+	c.cache.SetAccountOverhead(account)
+}
+
+// LogicalBytes 返回缓存中key和value的字节数
+func (c *KCache) LogicalBytes() int {
+	return c.cache.LogicalBytes()
+}
+
+// EstimatedBytes 返回缓存包含额外开销的估算字节数，不包含历史队列
+func (c *KCache) EstimatedBytes() int {
+	return c.cache.EstimatedBytes()
+}
+
+func (c *KCache) removeRecord(e *list.Element) {
+	c.history.Remove(e)
+	delete(c.records, e.Value.(*record).key)
+}
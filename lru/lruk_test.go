@@ -0,0 +1,58 @@
+package lru
+
+import "testing"
+
+func TestKCache_Add(t *testing.T) {
+	lruk := NewK(2, 10, 0, nil)
+	k1, v1 := "key1", &String{s: "value1"}
+	lruk.Add(k1, v1)
+	if _, ok := lruk.Get(k1); ok || lruk.Len() != 0 {
+		t.Fatalf("key %v accessed once should not be cached, len=%d\n", k1, lruk.Len())
+	}
+	lruk.Add(k1, v1)
+	if value, ok := lruk.Get(k1); !ok || value != v1 || lruk.Len() != 1 {
+		t.Fatalf("key %v accessed twice should be cached, len=%d\n", k1, lruk.Len())
+	}
+}
+
+func TestKCache_ScanResistant(t *testing.T) {
+	k1, k2 := "key1", "key2"
+	v1, v2 := &String{s: "value1"}, &String{s: "value2"}
+	capacity := len(k1) + v1.Len()
+	lruk := NewK(2, 10, capacity, nil)
+	lruk.Add(k1, v1)
+	lruk.Add(k1, v1)
+	// 一次性扫描的key不应该挤出已缓存的key
+	lruk.Add(k2, v2)
+	if _, ok := lruk.Get(k1); !ok {
+		t.Fatalf("key %v should not be evicted by one-hit key\n", k1)
+	}
+}
+
+func TestKCache_History(t *testing.T) {
+	lruk := NewK(2, 1, 0, nil)
+	k1, k2 := "key1", "key2"
+	v1, v2 := &String{s: "value1"}, &String{s: "value2"}
+	lruk.Add(k1, v1)
+	// 历史队列长度为1 k1的访问记录被k2挤出
+	lruk.Add(k2, v2)
+	lruk.Add(k1, v1)
+	if _, ok := lruk.Get(k1); ok {
+		t.Fatalf("history of key %v should be evicted\n", k1)
+	}
+	lruk.Remove(k1)
+	if len(lruk.records) != 0 || lruk.history.Len() != 0 {
+		t.Fatalf("remove should clear history, len=%d\n", lruk.history.Len())
+	}
+}
+
+func TestKCache_Set(t *testing.T) {
+	lruk := NewK(2, 10, 0, nil)
+	k1, v1 := "key1", &String{s: "value1"}
+	lruk.Add(k1, v1)
+	// 显式写入直接进入缓存 并清除访问记录
+	lruk.Set(k1, v1)
+	if value, ok := lruk.Get(k1); !ok || value != v1 || len(lruk.records) != 0 {
+		t.Fatalf("key %v set explicitly should be cached, records=%d\n", k1, len(lruk.records))
+	}
+}
@@ -0,0 +1,195 @@
+package gcache
+
+import (
+	"sync"
+	"time"
+)
+
+// memory 模块提供进程级的内存预算
+// 每个Group的cacheBytes相互独立，进程中有很多Group时无法控制总内存占用
+// MemoryManager 在注册的Group之间按权重或命中价值分配主缓存的容量，
+// 并在总占用超出预算时从价值最低的Group淘汰数据
+// 注意: 只有实现了Resizer的淘汰算法才能被调整容量，热点缓存计入总占用但不会被调整
+
+// MemoryManager 进程级的内存管理器
+type MemoryManager struct {
+	mu      sync.Mutex
+	limit   int                // 所有Group的总容量
+	byHits  bool               // 是否按命中价值分配容量
+	members map[*Group]*member // 注册的Group
+	stop    chan struct{}      // 关闭后停止后台协程
+}
+
+// member Group在内存管理器中的信息
+type member struct {
+	weight   int   // 权重
+	lastHits int64 // 上次分配容量时的命中次数
+}
+
+// NewMemoryManager 创建总容量为limit字节的内存管理器
+func NewMemoryManager(limit int) *MemoryManager {
+	if limit <= 0 {
+		panic("memory limit must be greater than 0")
+	}
+	return &MemoryManager{
+		limit:   limit,
+		members: make(map[*Group]*member),
+	}
+}
+
+// SetRebalanceByHits 设置是否按命中价值分配容量
+// 开启后每次分配时，Group分得的容量与 权重*(上个周期的命中次数+1) 成正比
+// 默认只按权重分配
+func (m *MemoryManager) SetRebalanceByHits(byHits bool) {
+	m.mu.Lock()
+	m.byHits = byHits
+	m.mu.Unlock()
+	m.Rebalance()
+}
+
+// Register 将Group注册到内存管理器 Group的主缓存容量将由内存管理器分配
+func (m *MemoryManager) Register(g *Group, weight int) {
+	if weight <= 0 {
+		panic("weight must be greater than 0")
+	}
+	m.mu.Lock()
+	if g.memory != nil && g.memory != m {
+		m.mu.Unlock()
+		panic("group had been registered to another memory manager")
+	}
+	g.memory = m
+	m.members[g] = &member{weight: weight, lastHits: g.hitCount()}
+	m.mu.Unlock()
+	m.Rebalance()
+}
+
+// Unregister 将Group从内存管理器移除 Group保持当前的容量
+func (m *MemoryManager) Unregister(g *Group) {
+	m.mu.Lock()
+	if _, ok := m.members[g]; !ok {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.members, g)
+	g.memory = nil
+	m.mu.Unlock()
+	m.Rebalance()
+}
+
+// Rebalance 重新在Group之间分配容量 然后检查总占用是否超出预算
+func (m *MemoryManager) Rebalance() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.members) == 0 {
+		return
+	}
+	scores := make(map[*Group]int64, len(m.members))
+	var total int64
+	for g, mb := range m.members {
+		score := int64(mb.weight)
+		hits := g.hitCount()
+		if m.byHits {
+			score *= hits - mb.lastHits + 1
+		}
+		mb.lastHits = hits
+		scores[g] = score
+		total += score
+	}
+	for g, score := range scores {
+		capacity := int(int64(m.limit) * score / total)
+		if capacity == 0 {
+			capacity = 1 // 0代表无限制 至少分配1字节
+		}
+		g.mainCache.resize(capacity)
+	}
+	m.enforce()
+}
+
+// Enforce 检查总占用 超出预算时从价值最低的Group开始缩小容量并淘汰数据
+func (m *MemoryManager) Enforce() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enforce()
+}
+
+func (m *MemoryManager) enforce() {
+	usage := m.usage()
+	excess := usage - m.limit
+	candidates := make(map[*Group]bool, len(m.members))
+	for g := range m.members {
+		candidates[g] = true
+	}
+	for excess > 0 && len(candidates) > 0 {
+		// Group的价值为上个周期每字节的命中次数乘以权重
+		var victim *Group
+		var victimValue float64
+		for g := range candidates {
+			mb := m.members[g]
+			value := float64(mb.weight) * float64(g.hitCount()-mb.lastHits+1) / float64(g.mainCache.bytes()+1)
+			if victim == nil || value < victimValue {
+				victim, victimValue = g, value
+			}
+		}
+		delete(candidates, victim)
+		before := victim.mainCache.bytes()
+		if before == 0 {
+			continue
+		}
+		capacity := before - excess
+		if capacity <= 0 {
+			capacity = 1 // 0代表无限制 至少保留1字节
+		}
+		victim.mainCache.resize(capacity)
+		excess -= before - victim.mainCache.bytes()
+	}
+}
+
+// Usage 返回所有注册的Group的主缓存和热点缓存的总占用
+func (m *MemoryManager) Usage() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage()
+}
+
+func (m *MemoryManager) usage() int {
+	usage := 0
+	for g := range m.members {
+		usage += g.mainCache.bytes()
+		if g.hotCache != nil {
+			usage += g.hotCache.bytes()
+		}
+	}
+	return usage
+}
+
+// Start 启动后台协程 每隔interval重新分配容量并检查总占用
+func (m *MemoryManager) Start(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.Rebalance()
+			}
+		}
+	}(m.stop)
+}
+
+// Stop 停止后台协程 如果没有启动 这将是一个no-op
+func (m *MemoryManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
@@ -0,0 +1,65 @@
+package gcache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newMemoryTestGroup(name string) *Group {
+	return NewGroup(name, 0, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView(make([]byte, 100), time.Time{}), nil
+	}))
+}
+
+func TestMemoryManager_Weight(t *testing.T) {
+	const limit = 64 << 10
+	m := NewMemoryManager(limit)
+	g1, g2 := newMemoryTestGroup("memory-weight-1"), newMemoryTestGroup("memory-weight-2")
+	m.Register(g1, 1)
+	m.Register(g2, 3)
+	defer DestroyGroup(g1.name)
+	defer DestroyGroup(g2.name)
+
+	for i := 0; i < 2000; i++ {
+		g1.Get(strconv.Itoa(i))
+		g2.Get(strconv.Itoa(i))
+	}
+	if usage := m.Usage(); usage > limit {
+		t.Fatalf("usage %d exceeds limit %d", usage, limit)
+	}
+	if b1, b2 := g1.mainCache.bytes(), g2.mainCache.bytes(); b2 < 2*b1 {
+		t.Fatalf("group with higher weight should get more memory, got %d and %d", b1, b2)
+	}
+}
+
+func TestMemoryManager_Enforce(t *testing.T) {
+	const limit = 32 << 10
+	m := NewMemoryManager(limit)
+	g1, g2 := newMemoryTestGroup("memory-enforce-1"), newMemoryTestGroup("memory-enforce-2")
+	g1.SetHotCache(limit / 4)
+	m.Register(g1, 1)
+	m.Register(g2, 1)
+	defer DestroyGroup(g1.name)
+	defer DestroyGroup(g2.name)
+
+	for i := 0; i < 1000; i++ {
+		g2.Get(strconv.Itoa(i))
+		g2.Get(strconv.Itoa(i)) // g2命中次数更多 价值更高
+		g1.Get(strconv.Itoa(i))
+		g1.hotCache.add(strconv.Itoa(i), NewByteView(make([]byte, 100), time.Time{}))
+	}
+	// 热点缓存使总占用超出预算
+	if usage := m.Usage(); usage <= limit {
+		t.Fatalf("usage %d should exceed limit %d before enforce", usage, limit)
+	}
+	before := g2.mainCache.bytes()
+	m.Enforce()
+	if usage := m.Usage(); usage > limit {
+		t.Fatalf("usage %d exceeds limit %d after enforce", usage, limit)
+	}
+	if g2.mainCache.bytes() != before {
+		t.Fatalf("more valuable group should not be evicted")
+	}
+}
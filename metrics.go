@@ -0,0 +1,112 @@
+package gcache
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juguagua/gCache/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metrics 模块以Prometheus文本格式导出缓存指标
+// 计数类指标在输出时从Group.Stats实时采集 延迟类指标在请求路径上记录
+
+var metricsRegistry = metrics.NewRegistry()
+
+var (
+	loadLatency = metricsRegistry.NewHistogramVec("gcache_load_duration_seconds",
+		"Latency of loading values from the local getter.", nil, "group")
+	peerFetchLatency = metricsRegistry.NewHistogramVec("gcache_peer_fetch_duration_seconds",
+		"Latency of fetching values from remote peers, by peer address.", nil, "group", "peer")
+	grpcHandled = metricsRegistry.NewCounterVec("gcache_grpc_server_handled_total",
+		"Total gRPC requests handled by the server, by method and status code.", "method", "code")
+)
+
+func init() {
+	groupCounter := func(name, help string, value func(GroupStats) int64) {
+		metricsRegistry.NewCounterFunc(name, help, func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, g := range allGroups() {
+				samples = append(samples, metrics.Sample{Labels: []string{g.name}, Value: float64(value(g.Stats()))})
+			}
+			return samples
+		}, "group")
+	}
+	groupCounter("gcache_gets_total", "Total Get requests.", func(s GroupStats) int64 { return s.Gets })
+	groupCounter("gcache_loads_total", "Total cache misses that required a load.", func(s GroupStats) int64 { return s.Loads })
+	groupCounter("gcache_loads_executed_total", "Total loads executed after singleflight deduplication.", func(s GroupStats) int64 { return s.LoadsExecuted })
+	groupCounter("gcache_peer_loads_total", "Total values loaded from remote peers.", func(s GroupStats) int64 { return s.PeerLoads })
+	groupCounter("gcache_peer_errors_total", "Total failed loads from remote peers.", func(s GroupStats) int64 { return s.PeerErrors })
+	groupCounter("gcache_local_loads_total", "Total values loaded from the local getter.", func(s GroupStats) int64 { return s.LocalLoads })
+	groupCounter("gcache_local_load_errors_total", "Total failed loads from the local getter.", func(s GroupStats) int64 { return s.LocalLoadErrs })
+	groupCounter("gcache_server_requests_total", "Total Get requests received from remote peers.", func(s GroupStats) int64 { return s.ServerRequests })
+
+	metricsRegistry.NewGaugeFunc("gcache_hit_ratio", "Ratio of Get requests served by the main or hot cache.", func() []metrics.Sample {
+		var samples []metrics.Sample
+		for _, g := range allGroups() {
+			s := g.Stats()
+			var ratio float64
+			if s.Gets > 0 {
+				ratio = float64(s.MainCacheHits+s.HotCacheHits) / float64(s.Gets)
+			}
+			samples = append(samples, metrics.Sample{Labels: []string{g.name}, Value: ratio})
+		}
+		return samples
+	}, "group")
+
+	// cache级别的指标 用cache标签区分主缓存和热点缓存
+	cacheMetric := func(value func(CacheStats) []metrics.Sample) metrics.Collector {
+		return func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, g := range allGroups() {
+				s := g.Stats()
+				for _, c := range []struct {
+					name  string
+					stats CacheStats
+				}{{"main", s.MainCache}, {"hot", s.HotCache}} {
+					for _, sample := range value(c.stats) {
+						sample.Labels = append([]string{g.name, c.name}, sample.Labels...)
+						samples = append(samples, sample)
+					}
+				}
+			}
+			return samples
+		}
+	}
+	metricsRegistry.NewGaugeFunc("gcache_cache_bytes", "Bytes counted against the cache capacity.", cacheMetric(func(s CacheStats) []metrics.Sample {
+		return []metrics.Sample{{Value: float64(s.Bytes)}}
+	}), "group", "cache")
+	metricsRegistry.NewGaugeFunc("gcache_cache_items", "Number of items in the cache.", cacheMetric(func(s CacheStats) []metrics.Sample {
+		return []metrics.Sample{{Value: float64(s.Items)}}
+	}), "group", "cache")
+	metricsRegistry.NewCounterFunc("gcache_cache_hits_total", "Total cache hits.", cacheMetric(func(s CacheStats) []metrics.Sample {
+		return []metrics.Sample{{Value: float64(s.Hits)}}
+	}), "group", "cache")
+	metricsRegistry.NewCounterFunc("gcache_cache_evictions_total", "Total entries removed from the cache, by reason.", cacheMetric(func(s CacheStats) []metrics.Sample {
+		return []metrics.Sample{
+			{Labels: []string{EvictCapacity.String()}, Value: float64(s.Evictions)},
+			{Labels: []string{EvictExpired.String()}, Value: float64(s.Expirations)},
+			{Labels: []string{EvictRemoved.String()}, Value: float64(s.Removals)},
+			{Labels: []string{EvictReplaced.String()}, Value: float64(s.Replacements)},
+		}
+	}), "group", "cache", "reason")
+}
+
+// MetricsHandler 返回以Prometheus文本格式输出所有指标的http.Handler
+func MetricsHandler() http.Handler {
+	return metricsRegistry
+}
+
+// observeSince 记录从start开始经过的秒数
+func observeSince(h *metrics.HistogramVec, start time.Time, labels ...string) {
+	h.Observe(time.Since(start).Seconds(), labels...)
+}
+
+// metricsInterceptor 统计grpc请求的状态码
+func metricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	grpcHandled.Inc(info.FullMethod, status.Code(err).String())
+	return resp, err
+}
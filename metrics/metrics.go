@@ -0,0 +1,341 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metrics 提供一个最小化的指标注册表 以Prometheus文本格式(0.0.4)输出
+// 仅支持带标签的counter、gauge和histogram 不依赖prometheus client库
+
+// ContentType Prometheus文本格式的Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets 默认的histogram桶 单位为秒 适合衡量网络和存储请求的延迟
+var DefBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample 一个采样值 Labels的顺序与指标定义的标签名一致
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// Collector 在输出时实时采集指标值的函数 用于从已有的统计信息生成gauge或counter
+type Collector func() []Sample
+
+// metric 所有指标的共同行为
+type metric interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry 指标注册表 并发安全
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[m.name()]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric %s", m.name()))
+	}
+	r.metrics[m.name()] = m
+}
+
+// WriteText 以Prometheus文本格式输出所有指标 指标按名称排序
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	ms := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		ms = append(ms, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(ms, func(i, j int) bool { return ms[i].name() < ms[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, m := range ms {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 实现http.Handler 可直接挂载到/metrics
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// desc 指标的描述信息
+type desc struct {
+	fqName string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) name() string { return d.fqName }
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.fqName, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.fqName, d.typ)
+}
+
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.fqName, len(d.labels), len(values)))
+	}
+}
+
+// CounterVec 按标签区分的单调递增计数器
+type CounterVec struct {
+	desc
+	mu     sync.RWMutex
+	values map[string]*counter
+}
+
+type counter struct {
+	labels []string
+	bits   uint64 // float64的位表示 原子更新
+}
+
+// NewCounterVec 创建并注册计数器
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{fqName: name, help: help, typ: "counter", labels: labels},
+		values: make(map[string]*counter),
+	}
+	r.register(c)
+	return c
+}
+
+// Inc 计数器加一
+func (c *CounterVec) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add 计数器增加v v必须非负
+func (c *CounterVec) Add(v float64, labels ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	addFloat(&c.get(labels).bits, v)
+}
+
+func (c *CounterVec) get(labels []string) *counter {
+	c.checkLabels(labels)
+	key := labelKey(labels)
+	c.mu.RLock()
+	v, ok := c.values[key]
+	c.mu.RUnlock()
+	if ok {
+		return v
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok = c.values[key]; !ok {
+		v = &counter{labels: append([]string(nil), labels...)}
+		c.values[key] = v
+	}
+	return v
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.desc.writeHeader(w)
+	c.mu.RLock()
+	samples := make([]Sample, 0, len(c.values))
+	for _, v := range c.values {
+		samples = append(samples, Sample{v.labels, math.Float64frombits(atomic.LoadUint64(&v.bits))})
+	}
+	c.mu.RUnlock()
+	writeSamples(w, c.fqName, c.labels, samples)
+}
+
+// HistogramVec 按标签区分的直方图
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.RWMutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	mu     sync.Mutex
+	counts []uint64 // 每个桶的计数 不累加 输出时再累加
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec 创建并注册直方图 buckets为各个桶的上界 需升序排列 为nil时使用DefBuckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of %s must be sorted", name))
+	}
+	h := &HistogramVec{
+		desc:    desc{fqName: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe 记录一次观测值
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	h.checkLabels(labels)
+	key := labelKey(labels)
+	h.mu.RLock()
+	hist, ok := h.values[key]
+	h.mu.RUnlock()
+	if !ok {
+		h.mu.Lock()
+		if hist, ok = h.values[key]; !ok {
+			hist = &histogram{labels: append([]string(nil), labels...), counts: make([]uint64, len(h.buckets))}
+			h.values[key] = hist
+		}
+		h.mu.Unlock()
+	}
+	i := sort.SearchFloat64s(h.buckets, v) // 第一个上界不小于v的桶
+	hist.mu.Lock()
+	if i < len(hist.counts) {
+		hist.counts[i]++
+	}
+	hist.count++
+	hist.sum += v
+	hist.mu.Unlock()
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.desc.writeHeader(w)
+	h.mu.RLock()
+	hists := make([]*histogram, 0, len(h.values))
+	for _, v := range h.values {
+		hists = append(hists, v)
+	}
+	h.mu.RUnlock()
+	sort.Slice(hists, func(i, j int) bool { return labelKey(hists[i].labels) < labelKey(hists[j].labels) })
+
+	names := append(append([]string(nil), h.labels...), "le")
+	values := make([]string, len(names))
+	for _, hist := range hists {
+		copy(values, hist.labels)
+		hist.mu.Lock()
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hist.counts[i]
+			values[len(values)-1] = formatFloat(upper)
+			writeSample(w, h.fqName+"_bucket", names, values, float64(cumulative))
+		}
+		values[len(values)-1] = "+Inf"
+		writeSample(w, h.fqName+"_bucket", names, values, float64(hist.count))
+		writeSample(w, h.fqName+"_sum", h.labels, hist.labels, hist.sum)
+		writeSample(w, h.fqName+"_count", h.labels, hist.labels, float64(hist.count))
+		hist.mu.Unlock()
+	}
+}
+
+// funcMetric 输出时通过Collector采集值的指标
+type funcMetric struct {
+	desc
+	collect Collector
+}
+
+// NewGaugeFunc 注册一个在输出时采集值的gauge
+func (r *Registry) NewGaugeFunc(name, help string, collect Collector, labels ...string) {
+	r.register(&funcMetric{desc{fqName: name, help: help, typ: "gauge", labels: labels}, collect})
+}
+
+// NewCounterFunc 注册一个在输出时采集值的counter 采集到的值需单调递增
+func (r *Registry) NewCounterFunc(name, help string, collect Collector, labels ...string) {
+	r.register(&funcMetric{desc{fqName: name, help: help, typ: "counter", labels: labels}, collect})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.desc.writeHeader(w)
+	samples := f.collect()
+	for _, s := range samples {
+		f.checkLabels(s.Labels)
+	}
+	writeSamples(w, f.fqName, f.labels, samples)
+}
+
+// writeSamples 按标签排序后输出采样值
+func writeSamples(w *bufio.Writer, name string, names []string, samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool { return labelKey(samples[i].Labels) < labelKey(samples[j].Labels) })
+	for _, s := range samples {
+		writeSample(w, name, names, s.Labels, s.Value)
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, names, values []string, v float64) {
+	w.WriteString(name)
+	if len(names) > 0 {
+		w.WriteByte('{')
+		for i, n := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(n)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabel(values[i]))
+			w.WriteByte('"')
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(bits, old, n) {
+			return
+		}
+	}
+}
+
+// labelKey 将标签值拼接为map的key 使用不会出现在合法UTF-8中的字节分隔
+func labelKey(labels []string) string {
+	return strings.Join(labels, "\xff")
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_requests_total", "Total requests.", "group", "code")
+	c.Inc("scores", "OK")
+	c.Add(2, "scores", "OK")
+	c.Inc("scores", `bad"code`)
+	h := r.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "group")
+	h.Observe(0.05, "scores")
+	h.Observe(0.5, "scores")
+	h.Observe(5, "scores")
+	r.NewGaugeFunc("test_items", "Items.", func() []Sample {
+		return []Sample{{Labels: []string{"scores"}, Value: 42}}
+	}, "group")
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expect := `# HELP test_items Items.
+# TYPE test_items gauge
+test_items{group="scores"} 42
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{group="scores",le="0.1"} 1
+test_latency_seconds_bucket{group="scores",le="1"} 2
+test_latency_seconds_bucket{group="scores",le="+Inf"} 3
+test_latency_seconds_sum{group="scores"} 5.55
+test_latency_seconds_count{group="scores"} 3
+# HELP test_requests_total Total requests.
+# TYPE test_requests_total counter
+test_requests_total{group="scores",code="OK"} 3
+test_requests_total{group="scores",code="bad\"code"} 1
+`
+	if got := buf.String(); got != expect {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestRegistry_Duplicate(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("dup_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic on duplicate metric")
+		}
+	}()
+	r.NewCounterVec("dup_total", "")
+}
+
+func TestCounterVec_Labels(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("labels_total", "", "a")
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(err.(string), "label values") {
+			t.Fatalf("expect label count panic, got %v", err)
+		}
+	}()
+	c.Inc("x", "y")
+}
@@ -0,0 +1,60 @@
+package gcache
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试指标输出
+func TestMetricsHandler(t *testing.T) {
+	g := NewGroup("metrics", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("metrics")
+	g.Get("Tom")
+	g.Get("Tom")
+	g.Delete("Tom")
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`gcache_gets_total{group="metrics"} 2`,
+		`gcache_hit_ratio{group="metrics"} 0.5`,
+		`gcache_cache_items{group="metrics",cache="main"} 0`,
+		`gcache_cache_evictions_total{group="metrics",cache="main",reason="removed"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("expect %q in metrics output:\n%s", line, body)
+		}
+	}
+	// 延迟直方图是全局的 重复运行测试时会累加 只检查是否存在
+	if !strings.Contains(body, `gcache_load_duration_seconds_count{group="metrics"} `) {
+		t.Fatalf("expect load latency in metrics output:\n%s", body)
+	}
+}
+
+// 测试远程请求延迟以节点地址为标签
+func TestMetricsHandler_PeerFetch(t *testing.T) {
+	NewGroup("peermetrics", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("peermetrics")
+	addr, stop := serveGroupCache(t, "127.0.0.1:0")
+	defer stop()
+	c := newClient("gcache/"+addr, addr, NewStaticDiscovery())
+	defer c.close()
+	if _, err := c.Fetch(context.Background(), "peermetrics", "Tom"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	line := `gcache_peer_fetch_duration_seconds_count{group="peermetrics",peer="` + addr + `"} `
+	if !strings.Contains(rec.Body.String(), line) {
+		t.Fatalf("expect %q in metrics output:\n%s", line, rec.Body.String())
+	}
+}
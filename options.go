@@ -0,0 +1,85 @@
+package gcache
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/juguagua/gCache/registry"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// options 模块集中管理server连接etcd和注册服务的配置
+
+const (
+	defaultServiceName = "gcache"        // 默认的服务名
+	defaultDialTimeout = 5 * time.Second // 默认的etcd首次连接超时时间
+	defaultLeaseTTL    = 5 * time.Second // 默认的租约过期时间
+)
+
+// ServerOptions server的配置 零值字段使用默认值
+type ServerOptions struct {
+	EtcdEndpoints []string      // etcd的多个服务节点地址 默认为localhost:2379
+	DialTimeout   time.Duration // etcd的首次连接超时时间 默认为5秒
+	TLS           *tls.Config   // 连接etcd使用的TLS配置 为nil时不使用TLS
+	Username      string        // etcd的用户名
+	Password      string        // etcd的密码
+	LeaseTTL      time.Duration // 注册节点的租约过期时间 节点异常退出后最多在该时长后被移除 默认为5秒
+	KeyPrefix     string        // etcd中所有key的前缀 用于多个集群共享同一个etcd
+	ServiceName   string        // 注册和发现节点使用的服务名 同一集群的节点需一致 默认为gcache
+
+	// 以下为本节点注册时发布的元数据
+	Weight   int      // 容量权重 决定本节点在哈希环上分得的key的比例 默认为1
+	Zone     string   // 可用区 key的归属节点在其他可用区时读请求先发往本可用区的读副本 不影响key的归属 读副本需开启热点缓存
+	Version  string   // 构建版本 形如v1.2.3 用于在升级期间拒绝路由到不兼容的节点
+	Features []string // 支持的rpc特性
+}
+
+// withDefaults 返回填充了默认值的配置
+func (o ServerOptions) withDefaults() ServerOptions {
+	if len(o.EtcdEndpoints) == 0 {
+		o.EtcdEndpoints = registry.DefaultEtcdConfig().Endpoints
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = defaultDialTimeout
+	}
+	if o.LeaseTTL == 0 {
+		o.LeaseTTL = defaultLeaseTTL
+	}
+	if o.ServiceName == "" {
+		o.ServiceName = defaultServiceName
+	}
+	if o.Weight < 1 {
+		o.Weight = 1
+	}
+	return o
+}
+
+// etcdConfig 返回连接etcd的配置
+func (o ServerOptions) etcdConfig() clientv3.Config {
+	return clientv3.Config{
+		Endpoints:   o.EtcdEndpoints,
+		DialTimeout: o.DialTimeout,
+		TLS:         o.TLS,
+		Username:    o.Username,
+		Password:    o.Password,
+	}
+}
+
+// etcdRegistry 返回按配置创建的etcd注册中心
+func (o ServerOptions) etcdRegistry() *registry.EtcdRegistry {
+	r := registry.NewEtcd(o.etcdConfig())
+	r.SetLeaseTTL(o.LeaseTTL)
+	r.SetKeyPrefix(o.KeyPrefix)
+	return r
+}
+
+// endpoint 返回本节点注册时发布的节点信息
+func (o ServerOptions) endpoint(addr string) registry.Endpoint {
+	return registry.Endpoint{
+		Addr:     addr,
+		Weight:   o.Weight,
+		Zone:     o.Zone,
+		Version:  o.Version,
+		Features: o.Features,
+	}
+}
@@ -1,5 +1,10 @@
 package gcache
 
+import (
+	"context"
+	"fmt"
+)
+
 // peers 模块
 
 // Picker 定义了获取分布式节点的能力
@@ -7,8 +12,41 @@ type Picker interface {
 	Pick(key string) (Fetcher, bool)
 }
 
-// Fetcher 定义了从远端获取缓存的能力
+// ReplicaPicker 可选接口 实现了该接口的Picker可以为key选出本可用区内的读副本
+// key的归属节点在其他可用区时 读请求先发往读副本 读副本从归属节点加载后放入自己的热点缓存
+// 从而使跨可用区的读请求收敛到每个可用区的一个节点上 key的归属和写请求不受影响
+type ReplicaPicker interface {
+	PickReplica(key string) (Fetcher, bool)
+}
+
+// Fetcher 定义了从远端获取、设置和删除缓存的能力
 // 所以每个Peer应实现这个接口
 type Fetcher interface {
-	Fetch(group string, key string) (ByteView, error)
+	Fetch(ctx context.Context, group string, key string) (ByteView, error)
+	Set(ctx context.Context, group string, key string, value ByteView) error
+	Delete(ctx context.Context, group string, key string) error
+}
+
+// replicaReadHeader 标记发往读副本的请求 读副本不再将其转发给其他读副本
+const replicaReadHeader = "gcache-replica-read"
+
+type replicaReadKey struct{}
+
+// withReplicaRead 标记ctx中的请求为读副本请求
+func withReplicaRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaReadKey{}, true)
+}
+
+// isReplicaRead ctx中的请求是否为读副本请求
+func isReplicaRead(ctx context.Context) bool {
+	v, _ := ctx.Value(replicaReadKey{}).(bool)
+	return v
+}
+
+// peerName 返回Fetcher对应远程节点的名称 用于日志
+func peerName(f Fetcher) string {
+	if s, ok := f.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", f)
 }
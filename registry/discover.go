@@ -1,22 +0,0 @@
-package registry
-
-import (
-	clientv3 "go.etcd.io/etcd/client/v3"
-	"go.etcd.io/etcd/client/v3/naming/resolver"
-	"google.golang.org/grpc"
-)
-
-// EtcdDial 向grpc请求一个服务
-// 通过提供一个etcd client和service name即可获得Connection
-func EtcdDial(c *clientv3.Client, service string) (*grpc.ClientConn, error) {
-	etcdResolver, err := resolver.NewBuilder(c)
-	if err != nil {
-		return nil, err
-	}
-	return grpc.Dial(
-		"etcd:///"+service,
-		grpc.WithResolvers(etcdResolver),
-		grpc.WithInsecure(),
-		grpc.WithBlock(),
-	)
-}
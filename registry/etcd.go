@@ -0,0 +1,355 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juguagua/gCache/logging"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+)
+
+const (
+	defaultLeaseTTL = 5 * time.Second // 默认的租约过期时间
+	revokeTimeout   = 2 * time.Second // 关闭时撤销租约的超时时间
+)
+
+// EtcdRegistry 基于etcd的注册中心 节点以租约方式注册 进程退出后租约过期节点自动移除
+type EtcdRegistry struct {
+	config    clientv3.Config
+	leaseTTL  time.Duration // 租约过期时间 进程退出后节点最多保留该时长
+	keyPrefix string        // 所有key的前缀 节点的key为 keyPrefix+service/addr
+
+	mu       sync.Mutex
+	cli      *clientv3.Client         // 懒加载的etcd client
+	regs     map[string]*registration // 节点key到注册信息的映射
+	handlers map[string]StatusFunc    // 节点key到注册状态回调的映射
+	logger   logging.Logger
+}
+
+// NewEtcd 创建基于etcd的注册中心 首次使用时才建立连接
+func NewEtcd(config clientv3.Config) *EtcdRegistry {
+	return &EtcdRegistry{config: config, leaseTTL: defaultLeaseTTL, regs: make(map[string]*registration),
+		handlers: make(map[string]StatusFunc), logger: logging.Default()}
+}
+
+// SetLogger 设置日志 为nil时丢弃所有日志
+func (r *EtcdRegistry) SetLogger(logger logging.Logger) {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger = logger
+}
+
+func (r *EtcdRegistry) log() logging.Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.logger
+}
+
+// SetLeaseTTL 设置注册节点时的租约过期时间 不足1秒按1秒计算 需在Register之前调用
+func (r *EtcdRegistry) SetLeaseTTL(ttl time.Duration) {
+	r.leaseTTL = ttl
+}
+
+// SetKeyPrefix 设置所有key的前缀 用于多个集群共享同一个etcd 需在使用之前调用
+func (r *EtcdRegistry) SetKeyPrefix(prefix string) {
+	r.keyPrefix = prefix
+}
+
+// target 返回service下所有节点key的公共前缀
+func (r *EtcdRegistry) target(service string) string {
+	return r.keyPrefix + service + "/"
+}
+
+// client 获取etcd client 首次调用时才建立连接
+func (r *EtcdRegistry) client() (*clientv3.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cli != nil {
+		return r.cli, nil
+	}
+	cli, err := clientv3.New(r.config)
+	if err != nil {
+		return nil, fmt.Errorf("create etcd client failed: %v", err)
+	}
+	r.cli = cli
+	return cli, nil
+}
+
+// registration 一个由本Registry注册的节点
+type registration struct {
+	service string
+	ep      Endpoint
+	ctx     context.Context // 注销时取消 停止心跳和重新注册
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	leaseID clientv3.LeaseID
+}
+
+// Register 以租约模式注册节点 并在后台保持心跳
+// 失去租约后(例如etcd短暂不可用导致心跳中断) 以指数退避不断申请新的租约重新注册 直到Deregister或Close
+func (r *EtcdRegistry) Register(ctx context.Context, service string, ep Endpoint) error {
+	key := r.target(service) + ep.Addr
+	reg := &registration{service: service, ep: ep}
+	reg.ctx, reg.cancel = context.WithCancel(context.Background())
+	ch, err := r.register(ctx, reg)
+	if err != nil {
+		reg.cancel()
+		return err
+	}
+	r.mu.Lock()
+	old := r.regs[key]
+	r.regs[key] = reg
+	r.mu.Unlock()
+	if old != nil {
+		r.deregister(ctx, old)
+	}
+	r.notify(reg, StatusRegistered, nil)
+	go r.keep(reg, ch)
+	return nil
+}
+
+// register 申请租约并写入节点 返回心跳响应的channel
+func (r *EtcdRegistry) register(ctx context.Context, reg *registration) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	cli, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+	// 创建一个租约
+	ttl := int64((r.leaseTTL + time.Second - 1) / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	resp, err := cli.Grant(ctx, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("create lease failed: %v", err)
+	}
+	leaseID := resp.ID
+	// 注册服务
+	key := r.target(reg.service) + reg.ep.Addr
+	em, err := endpoints.NewManager(cli, r.target(reg.service)) // manger是etcd的管理中心
+	if err != nil {
+		return nil, err
+	}
+	err = em.AddEndpoint(ctx, key, endpoints.Endpoint{Addr: reg.ep.Addr, Metadata: reg.ep}, clientv3.WithLease(leaseID))
+	if err != nil {
+		cli.Revoke(ctx, leaseID)
+		return nil, fmt.Errorf("add etcd record failed: %v", err)
+	}
+	// 设置服务心跳检测 心跳在租约被撤销、过期或reg注销时停止
+	ch, err := cli.KeepAlive(reg.ctx, leaseID)
+	if err != nil {
+		cli.Revoke(ctx, leaseID)
+		return nil, fmt.Errorf("set keepalive failed: %v", err)
+	}
+	reg.mu.Lock()
+	reg.leaseID = leaseID
+	reg.mu.Unlock()
+	logging.Info(r.log(), "register service ok", logging.F("service", reg.service), logging.F("addr", reg.ep.Addr))
+	return ch, nil
+}
+
+// keep 保持心跳 失去租约后以指数退避重新注册 直到reg被注销
+func (r *EtcdRegistry) keep(reg *registration, ch <-chan *clientv3.LeaseKeepAliveResponse) {
+	var backoff Backoff
+	for {
+		for range ch {
+		}
+		if reg.ctx.Err() != nil {
+			return
+		}
+		err := fmt.Errorf("keep alive channel closed")
+		logging.Warn(r.log(), "lease lost, re-registering", logging.F("service", reg.service), logging.F("addr", reg.ep.Addr))
+		r.notify(reg, StatusLost, err)
+		backoff.Reset()
+		for {
+			select {
+			case <-reg.ctx.Done():
+				return
+			case <-time.After(backoff.Next()):
+			}
+			ctx, cancel := context.WithTimeout(reg.ctx, r.leaseTTL)
+			ch, err = r.register(ctx, reg)
+			cancel()
+			if err == nil {
+				break
+			}
+			if reg.ctx.Err() != nil {
+				return
+			}
+			logging.Warn(r.log(), "re-register failed", logging.F("service", reg.service), logging.F("addr", reg.ep.Addr), logging.Err(err))
+			r.notify(reg, StatusLost, err)
+		}
+		r.notify(reg, StatusRegistered, nil)
+	}
+}
+
+// SetStatusHandler 设置service下addr的注册状态回调 fn为nil时移除 需在Register之前调用
+func (r *EtcdRegistry) SetStatusHandler(service string, addr string, fn StatusFunc) {
+	key := r.target(service) + addr
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fn == nil {
+		delete(r.handlers, key)
+		return
+	}
+	r.handlers[key] = fn
+}
+
+func (r *EtcdRegistry) notify(reg *registration, status Status, err error) {
+	r.mu.Lock()
+	fn := r.handlers[r.target(reg.service)+reg.ep.Addr]
+	r.mu.Unlock()
+	if fn != nil {
+		fn(reg.service, reg.ep.Addr, status, err)
+	}
+}
+
+// Deregister 停止心跳并撤销节点的租约 节点记录随之删除
+func (r *EtcdRegistry) Deregister(ctx context.Context, service string, addr string) error {
+	key := r.target(service) + addr
+	r.mu.Lock()
+	reg, ok := r.regs[key]
+	delete(r.regs, key)
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	if err := r.deregister(ctx, reg); err != nil {
+		return err
+	}
+	r.notify(reg, StatusDeregistered, nil)
+	logging.Info(r.log(), "deregister service ok", logging.F("service", service), logging.F("addr", addr))
+	return nil
+}
+
+// deregister 停止reg的心跳和重新注册 并撤销当前的租约
+func (r *EtcdRegistry) deregister(ctx context.Context, reg *registration) error {
+	reg.cancel()
+	reg.mu.Lock()
+	leaseID := reg.leaseID
+	reg.mu.Unlock()
+	r.mu.Lock()
+	cli := r.cli
+	r.mu.Unlock()
+	if cli == nil {
+		return nil
+	}
+	if _, err := cli.Revoke(ctx, leaseID); err != nil {
+		return fmt.Errorf("revoke lease failed: %v", err)
+	}
+	return nil
+}
+
+// Watch 监听service下注册的所有节点 节点注册或租约过期时给出完整的节点列表
+func (r *EtcdRegistry) Watch(ctx context.Context, service string, onChange func(eps []Endpoint)) error {
+	cli, err := r.client()
+	if err != nil {
+		return err
+	}
+	// 先建立监听再列出节点 避免错过两者之间的变化
+	wch := cli.Watch(ctx, r.target(service), clientv3.WithPrefix())
+	list := func() error {
+		eps, err := r.List(ctx, service)
+		if err != nil {
+			return err
+		}
+		onChange(eps)
+		return nil
+	}
+	if err := list(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resp, ok := <-wch:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return fmt.Errorf("watch %s closed", service)
+			}
+			if err := resp.Err(); err != nil {
+				return err
+			}
+			if err := list(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// List 返回service下当前注册的所有节点及其元数据
+func (r *EtcdRegistry) List(ctx context.Context, service string) ([]Endpoint, error) {
+	cli, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cli.Get(ctx, r.target(service), clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	eps := make([]Endpoint, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		ep, err := decodeEndpoint(kv.Value)
+		if err != nil {
+			continue
+		}
+		eps = append(eps, ep)
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].Addr < eps[j].Addr })
+	return eps, nil
+}
+
+// decodeEndpoint 解析endpoints.Manager写入的节点记录 元数据保存在Metadata中
+// 旧版本节点没有写入元数据 解析后只有地址
+func decodeEndpoint(value []byte) (Endpoint, error) {
+	var record struct {
+		Addr     string
+		Metadata Endpoint
+	}
+	if err := json.Unmarshal(value, &record); err != nil {
+		return Endpoint{}, err
+	}
+	ep := record.Metadata
+	ep.Addr = record.Addr
+	return ep, nil
+}
+
+// Close 注销所有由本Registry注册的节点并关闭etcd client 之后再次使用会重新建立连接
+func (r *EtcdRegistry) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), revokeTimeout)
+	defer cancel()
+	r.mu.Lock()
+	regs := r.regs
+	r.regs = make(map[string]*registration)
+	r.mu.Unlock()
+	for _, reg := range regs {
+		r.deregister(ctx, reg)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cli == nil {
+		return nil
+	}
+	err := r.cli.Close()
+	r.cli = nil
+	return err
+}
+
+// 测试EtcdRegistry是否实现了Registry和StatusNotifier接口
+var (
+	_ Registry       = (*EtcdRegistry)(nil)
+	_ StatusNotifier = (*EtcdRegistry)(nil)
+)
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/juguagua/gCache/logging"
+	"go.etcd.io/etcd/client/v3/naming/endpoints"
+)
+
+// 测试元数据随endpoints.Manager的记录格式往返
+func TestDecodeEndpoint(t *testing.T) {
+	ep := Endpoint{Addr: "127.0.0.1:7001", Weight: 2, Zone: "a", Version: "v1.2.0", Features: []string{"stats"}}
+	value, err := json.Marshal(endpoints.Endpoint{Addr: ep.Addr, Metadata: ep})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeEndpoint(value)
+	if err != nil || !reflect.DeepEqual(got, ep) {
+		t.Fatalf("expect %+v, got %+v, err %v", ep, got, err)
+	}
+	if !got.HasFeature("stats") || got.HasFeature("batch") {
+		t.Fatalf("unexpected features %v", got.Features)
+	}
+
+	// 旧版本节点只写入了地址
+	got, err = decodeEndpoint([]byte(`{"Op":0,"Addr":"127.0.0.1:7002","Metadata":null}`))
+	if err != nil || !reflect.DeepEqual(got, Endpoint{Addr: "127.0.0.1:7002"}) {
+		t.Fatalf("unexpected endpoint %+v, err %v", got, err)
+	}
+}
+
+// 测试日志按Registry设置
+func TestEtcdRegistry_SetLogger(t *testing.T) {
+	r1, r2 := NewEtcd(DefaultEtcdConfig()), NewEtcd(DefaultEtcdConfig())
+	l := logging.NewStd(log.New(io.Discard, "", 0), logging.LevelDebug)
+	r1.SetLogger(l)
+	if r1.log() != l || r2.log() != logging.Default() {
+		t.Fatal("logger should be set per registry")
+	}
+	r1.SetLogger(nil)
+	if r1.log() != logging.Nop() {
+		t.Fatal("nil logger should discard logs")
+	}
+}
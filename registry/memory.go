@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryRegistry 基于内存的注册中心 用于测试以及在单个进程内运行多个节点
+// 同一个MemoryRegistry可以被多个server共享
+type MemoryRegistry struct {
+	mu       sync.Mutex
+	services map[string]map[string]Endpoint        // service到节点的映射
+	watchers map[string]map[chan struct{}]struct{} // service到监听者的映射
+	handlers map[string]StatusFunc                 // service/addr到注册状态回调的映射
+}
+
+// NewMemory 创建基于内存的注册中心
+func NewMemory() *MemoryRegistry {
+	return &MemoryRegistry{
+		services: make(map[string]map[string]Endpoint),
+		watchers: make(map[string]map[chan struct{}]struct{}),
+		handlers: make(map[string]StatusFunc),
+	}
+}
+
+// Register 注册节点 同一地址重复注册时覆盖
+func (r *MemoryRegistry) Register(_ context.Context, service string, ep Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	eps, ok := r.services[service]
+	if !ok {
+		eps = make(map[string]Endpoint)
+		r.services[service] = eps
+	}
+	eps[ep.Addr] = ep
+	r.notify(service)
+	if fn := r.handlers[service+"/"+ep.Addr]; fn != nil {
+		fn(service, ep.Addr, StatusRegistered, nil)
+	}
+	return nil
+}
+
+// Deregister 移除节点
+func (r *MemoryRegistry) Deregister(_ context.Context, service string, addr string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.services[service][addr]; !ok {
+		return nil
+	}
+	delete(r.services[service], addr)
+	r.notify(service)
+	if fn := r.handlers[service+"/"+addr]; fn != nil {
+		fn(service, addr, StatusDeregistered, nil)
+	}
+	return nil
+}
+
+// SetStatusHandler 设置service下addr的注册状态回调 fn为nil时移除
+// 回调在持有锁时调用 不能再调用本Registry的方法
+func (r *MemoryRegistry) SetStatusHandler(service string, addr string, fn StatusFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fn == nil {
+		delete(r.handlers, service+"/"+addr)
+		return
+	}
+	r.handlers[service+"/"+addr] = fn
+}
+
+// notify 通知service的所有监听者 调用方需持有锁
+func (r *MemoryRegistry) notify(service string) {
+	for ch := range r.watchers[service] {
+		// 通知只需要一个 监听者被唤醒后读取最新的节点列表
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Watch 监听service下的节点 阻塞直到ctx取消
+func (r *MemoryRegistry) Watch(ctx context.Context, service string, onChange func(eps []Endpoint)) error {
+	ch := make(chan struct{}, 1)
+	ch <- struct{}{} // 首次给出当前节点
+	r.mu.Lock()
+	if r.watchers[service] == nil {
+		r.watchers[service] = make(map[chan struct{}]struct{})
+	}
+	r.watchers[service][ch] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.watchers[service], ch)
+		r.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch:
+			eps, _ := r.List(ctx, service)
+			onChange(eps)
+		}
+	}
+}
+
+// List 返回service下的所有节点
+func (r *MemoryRegistry) List(_ context.Context, service string) ([]Endpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	eps := make([]Endpoint, 0, len(r.services[service]))
+	for _, ep := range r.services[service] {
+		eps = append(eps, ep)
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].Addr < eps[j].Addr })
+	return eps, nil
+}
+
+// Close 内存注册中心可能被多个server共享 因此不移除任何节点
+func (r *MemoryRegistry) Close() error {
+	return nil
+}
+
+// 测试MemoryRegistry是否实现了Registry和StatusNotifier接口
+var (
+	_ Registry       = (*MemoryRegistry)(nil)
+	_ StatusNotifier = (*MemoryRegistry)(nil)
+)
@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryRegistry(t *testing.T) {
+	r := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan []Endpoint, 10)
+	done := make(chan error)
+	go func() {
+		done <- r.Watch(ctx, "gcache", func(eps []Endpoint) { updates <- eps })
+	}()
+	expect := func(addrs ...string) {
+		t.Helper()
+		want := make([]Endpoint, len(addrs))
+		for i, addr := range addrs {
+			want[i] = Endpoint{Addr: addr}
+		}
+		select {
+		case eps := <-updates:
+			if !reflect.DeepEqual(eps, want) {
+				t.Fatalf("expect %v, got %v", want, eps)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expect update %v", want)
+		}
+	}
+	expect()
+
+	r.Register(ctx, "gcache", Endpoint{Addr: "127.0.0.1:7002"})
+	expect("127.0.0.1:7002")
+	r.Register(ctx, "gcache", Endpoint{Addr: "127.0.0.1:7001"})
+	expect("127.0.0.1:7001", "127.0.0.1:7002")
+	r.Register(ctx, "other", Endpoint{Addr: "127.0.0.1:8001"})
+	r.Deregister(ctx, "gcache", "127.0.0.1:7002")
+	expect("127.0.0.1:7001")
+
+	if eps, _ := r.List(ctx, "other"); len(eps) != 1 || eps[0].Addr != "127.0.0.1:8001" {
+		t.Fatalf("unexpected endpoints of other: %v", eps)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("watch should stop with context canceled, got %v", err)
+	}
+}
@@ -2,74 +2,52 @@ package registry
 
 import (
 	"context"
-	"fmt"
-	clientv3 "go.etcd.io/etcd/client/v3"
-	"go.etcd.io/etcd/client/v3/naming/endpoints"
-	"log"
 	"time"
-)
 
-var (
-	defaultEtcdConfig = clientv3.Config{
-		Endpoints:   []string{"localhost:2379"}, // etcd 服务节点
-		DialTimeout: 5 * time.Second,            // 超时连接时间
-	}
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
-// etcdAdd 在租赁模式添加一对kv至etcd
-func etcdAdd(c *clientv3.Client, lid clientv3.LeaseID, service string, addr string) error {
-	em, err := endpoints.NewManager(c, service) // manger是etcd的管理中心
-	if err != nil {
-		return err
-	}
-	return em.AddEndpoint(c.Ctx(), service+"/"+addr, endpoints.Endpoint{Addr: addr}, clientv3.WithLease(lid))
+// registry 模块负责服务的注册与发现
+// Registry定义了统一的接口 提供基于etcd和基于内存的两种实现
+
+// Endpoint 一个已注册的服务节点及其元数据
+type Endpoint struct {
+	Addr     string   `json:"-"`                  // 节点地址 ip:port
+	Weight   int      `json:"weight,omitempty"`   // 容量权重 决定节点在哈希环上的虚拟节点数量 为0时视为1
+	Zone     string   `json:"zone,omitempty"`     // 可用区
+	Version  string   `json:"version,omitempty"`  // 构建版本 形如v1.2.3
+	Features []string `json:"features,omitempty"` // 支持的rpc特性
 }
 
-// Register 注册一个服务至etcd
-// 注意 Register将不会return 如果没有error的话
-func Register(service string, addr string, stop chan error) error {
-	// 创建一个etcd client
-	cli, err := clientv3.New(defaultEtcdConfig)
-	if err != nil {
-		return fmt.Errorf("create etcd client failed: %v", err)
-	}
-	defer cli.Close()
-	// 创建一个租约 配置5秒过期
-	resp, err := cli.Grant(context.Background(), 5)
-	if err != nil {
-		return fmt.Errorf("create lease failed: %v", err)
-	}
-	leaseId := resp.ID
-	// 注册服务
-	err = etcdAdd(cli, leaseId, service, addr)
-	if err != nil {
-		return fmt.Errorf("add etcd record failed: %v", err)
-	}
-	// 设置服务心跳检测
-	ch, err := cli.KeepAlive(context.Background(), leaseId)
-	if err != nil {
-		return fmt.Errorf("set keepalive failed: %v", err)
+// HasFeature 节点是否支持feature
+func (ep Endpoint) HasFeature(feature string) bool {
+	for _, f := range ep.Features {
+		if f == feature {
+			return true
+		}
 	}
+	return false
+}
 
-	log.Printf("[%s] register service ok\n", addr)
-	for {
-		select {
-		case err := <-stop:
-			if err != nil {
-				log.Println(err)
-			}
-			return err
-		case <-cli.Ctx().Done():
-			log.Println("service closed")
-			return nil
-		case _, ok := <-ch:
-			// 监听租约
-			if !ok {
-				log.Println("keep alive channel closed")
-				_, err := cli.Revoke(context.Background(), leaseId)
-				return err
-			}
-			//log.Printf("Recv reply from service: %s/%s, ttl:%d", service, addr, resp.TTL)
-		}
+// Registry 服务注册中心
+type Registry interface {
+	// Register 将节点注册到service下 返回后节点即可被发现 直到Deregister或Close
+	Register(ctx context.Context, service string, ep Endpoint) error
+	// Deregister 将addr从service下移除 addr未注册时不返回错误
+	Deregister(ctx context.Context, service string, addr string) error
+	// Watch 监听service下的所有节点 首次调用onChange时给出当前节点 之后每次变化时给出完整的节点列表
+	// 阻塞直到ctx取消或监听出错
+	Watch(ctx context.Context, service string, onChange func(eps []Endpoint)) error
+	// List 返回service下当前的所有节点 按地址排序
+	List(ctx context.Context, service string) ([]Endpoint, error)
+	// Close 释放资源 之后仍可再次使用
+	Close() error
+}
+
+// DefaultEtcdConfig 返回连接本地etcd的默认配置
+func DefaultEtcdConfig() clientv3.Config {
+	return clientv3.Config{
+		Endpoints:   []string{"localhost:2379"}, // etcd 服务节点
+		DialTimeout: 5 * time.Second,            // 超时连接时间
 	}
 }
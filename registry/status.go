@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Status 节点的注册状态
+type Status int
+
+const (
+	StatusRegistered   Status = iota // 注册成功 包括失去租约后重新注册成功
+	StatusLost                       // 注册失败或失去租约 正在重试
+	StatusDeregistered               // 已注销
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusRegistered:
+		return "registered"
+	case StatusLost:
+		return "lost"
+	case StatusDeregistered:
+		return "deregistered"
+	}
+	return "unknown"
+}
+
+// StatusFunc 注册状态变化时的回调 err为导致StatusLost的错误
+type StatusFunc func(service string, addr string, status Status, err error)
+
+// StatusNotifier 可选接口 实现了该接口的Registry在节点注册状态变化时调用回调
+// 回调按节点设置 多个节点共享同一个Registry时各自只收到自己的注册状态
+type StatusNotifier interface {
+	// SetStatusHandler 设置service下addr的注册状态回调 fn为nil时移除
+	SetStatusHandler(service string, addr string, fn StatusFunc)
+}
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond // 第一次重试的等待时间
+	defaultMaxBackoff = 30 * time.Second       // 重试等待时间的上限
+)
+
+// Backoff 带随机抖动的指数退避 不是并发安全的
+type Backoff struct {
+	Min     time.Duration // 第一次重试的等待时间 为0时使用默认值
+	Max     time.Duration // 重试等待时间的上限 为0时使用默认值
+	attempt int
+}
+
+// Next 返回下一次重试前需要等待的时间 每次调用等待时间翻倍 并在[d/2, d)之间随机抖动
+func (b *Backoff) Next() time.Duration {
+	min, max := b.Min, b.Max
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := min
+	for i := 0; i < b.attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	b.attempt++
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Reset 重置重试次数
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
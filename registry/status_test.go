@@ -0,0 +1,20 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: 100 * time.Millisecond, Max: time.Second}
+	for i, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		if d := b.Next(); d < max/2 || d > max {
+			t.Fatalf("attempt %d: expect backoff in [%v, %v], got %v", i, max/2, max, d)
+		}
+	}
+	b.Reset()
+	if d := b.Next(); d > 100*time.Millisecond {
+		t.Fatalf("expect backoff reset, got %v", d)
+	}
+}
@@ -3,17 +3,18 @@ package gcache
 import (
 	"context"
 	"fmt"
-	"log"
 	"net"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/juguagua/gCache/consistenthash"
 	pb "github.com/juguagua/gCache/gcachepb"
+	"github.com/juguagua/gCache/logging"
 	"github.com/juguagua/gCache/registry"
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // server 模块为cache之间提供通信能力
@@ -24,44 +25,130 @@ const (
 	defaultBasePath = "/_gcache/"
 	defaultAddr     = "127.0.0.1:6324"
 	defaultReplicas = 50 // 虚拟节点倍数
-)
 
-var (
-	defaultEtcdConfig = clientv3.Config{
-		Endpoints:   []string{"localhost:2379"}, // etcd的多个服务节点地址
-		DialTimeout: 5 * time.Second,            // etcd的首次连接超时时间
-	}
+	watchRetryInterval = time.Second // 监听节点失败后的重试间隔
 )
 
 // server 和 Group 是解耦合的 所以server要自己实现并发控制
 type server struct {
 	pb.UnimplementedGroupCacheServer
 
-	addr       string     // format: ip:port
-	status     bool       // 服务状态 true: running    false: stop
-	stopSignal chan error // 通知registry revoke服务
-	mu         sync.Mutex
-	consHash   *consistenthash.Consistence
-	clients    map[string]*client
+	addr        string            // format: ip:port
+	local       registry.Endpoint // 本节点注册时发布的元数据
+	service     string            // 注册和发现节点使用的服务名
+	status      bool              // 服务状态 true: running    false: stop
+	stopSignal  chan error        // 通知registry revoke服务
+	mu          sync.Mutex
+	consHash    *consistenthash.Consistence
+	zoneHash    *consistenthash.Consistence // 与本节点同一可用区的节点组成的哈希环 用于选择读副本
+	clients     map[string]*client
+	peers       map[string]registry.Endpoint // 哈希环上各节点的元数据
+	compatible  CompatibleFunc               // 判断节点是否兼容 不兼容的节点不加入哈希环
+	discovery   Discovery                    // 服务注册与发现 所有client共享
+	done        chan struct{}                // 通知空闲连接清理协程退出
+	metricsAddr string                       // 指标http服务的监听地址 为空时不启动
+	metricsSvr  *http.Server
+	logger      logging.Logger // 日志 热路径上只输出Debug级别
+	watchPeers  time.Duration  // 节点变化后等待多久才重建哈希环 为0时不自动感知节点
+
+	// 注册状态回调由注册中心在持有其内部锁时调用 使用独立的锁 避免与mu形成死锁
+	statusMu sync.Mutex
+	onStatus func(status registry.Status, err error) // 可选 注册状态变化时调用
 }
 
-// NewServer 创建cache的server 若addr为空 则使用defaultAddr
+// NewServer 使用默认配置创建cache的server 若addr为空 则使用defaultAddr
 func NewServer(addr string) (*server, error) {
+	return NewServerWithOptions(addr, ServerOptions{})
+}
+
+// NewServerWithOptions 创建cache的server 通过opts配置etcd连接和服务名
+// 若addr为空 则使用defaultAddr
+func NewServerWithOptions(addr string, opts ServerOptions) (*server, error) {
 	if addr == "" {
 		addr = defaultAddr
 	}
 	if !validPeerAddr(addr) {
 		return nil, fmt.Errorf("invalid addr %s, it should be x.x.x.x:port", addr)
 	}
-	return &server{addr: addr}, nil
+	opts = opts.withDefaults()
+	return &server{
+		addr:       addr,
+		local:      opts.endpoint(addr),
+		service:    opts.ServiceName,
+		discovery:  NewEtcdDiscovery(opts),
+		compatible: SameMajorVersion,
+		logger:     logging.Default(),
+	}, nil
+}
+
+// SetDiscovery 设置服务注册与发现的方式 默认使用etcd 需在Start和SetPeers之前调用
+// 使用NewStaticDiscovery时节点之间按SetPeers给出的地址直接连接 无需etcd
+func (s *server) SetDiscovery(discovery Discovery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discovery = discovery
+}
+
+// SetPeerWatch 开启节点自动感知 需在Start之前调用 Discovery需实现PeerWatcher
+// server启动后监听服务发现中注册的节点 节点注册或租约过期时自动重建哈希环和client
+// 节点在debounce时间内没有再次变化才会重建 避免滚动发布期间哈希环频繁抖动
+// debounce为0时关闭自动感知 此时需手动调用SetPeers
+func (s *server) SetPeerWatch(debounce time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchPeers = debounce
+}
+
+// SetStatusHandler 设置本节点注册状态变化时的回调 需在Start之前调用
+// 失去etcd租约时回调StatusLost 节点继续提供服务并在后台重新注册 成功后回调StatusRegistered
+func (s *server) SetStatusHandler(fn func(status registry.Status, err error)) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.onStatus = fn
+}
+
+// SetCompatibility 设置判断节点是否兼容的策略 默认为SameMajorVersion 需在SetPeers之前调用
+// 为nil时不检查兼容性
+func (s *server) SetCompatibility(fn CompatibleFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compatible = fn
+}
+
+// SetLogger 设置日志 为nil时丢弃所有日志 需在Start之前调用
+// 同时设置当前服务发现的日志 使用SetDiscovery替换服务发现时需在其之后调用
+func (s *server) SetLogger(logger logging.Logger) {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+	if l, ok := s.discovery.(loggerSetter); ok {
+		l.SetLogger(logger)
+	}
+}
+
+// SetMetricsAddr 设置指标http服务的监听地址 server启动时在该地址的/metrics上
+// 以Prometheus文本格式输出指标 需在Start之前调用
+func (s *server) SetMetricsAddr(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metricsAddr = addr
 }
 
 // Get 实现cache service的Get接口
-func (s *server) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
+func (s *server) Get(ctx context.Context, in *pb.GetRequest) (resp *pb.GetResponse, err error) {
 	group, key := in.GetGroup(), in.GetKey()
-	resp := &pb.GetResponse{}
-
-	log.Printf("[peanutcache_svr %s] Recv RPC Request - (%s)/(%s)", s.addr, group, key)
+	resp = &pb.GetResponse{}
+	ctx, span := startSpan(ctx, "gcache.server.Get", group, key)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+	if s.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(s.logger, "recv rpc get", logging.F("addr", s.addr), logging.Group(group), logging.KeyHash(key))
+	}
 	if key == "" {
 		return resp, fmt.Errorf("key required")
 	}
@@ -69,14 +156,93 @@ func (s *server) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, e
 	if g == nil {
 		return resp, fmt.Errorf("group not found")
 	}
-	view, err := g.Get(key)
+	g.stats.serverRequests.Add(1)
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(replicaReadHeader)) > 0 {
+		ctx = withReplicaRead(ctx)
+	}
+	view, err := g.GetContext(ctx, key)
 	if err != nil {
 		return resp, err
 	}
 	resp.Value = view.ByteSlice()
+	// 传递过期时间 使远程节点的热点缓存与本节点同时过期
+	if !view.Expire().IsZero() {
+		resp.Expire = view.Expire().UnixNano()
+	}
+	resp.Version, resp.Flags = view.Version(), view.Flags()
+	return resp, nil
+}
+
+// Set 实现cache service的Set接口
+// 请求已由调用方路由至本节点 因此直接写入本地 避免节点间视图不一致时来回转发
+func (s *server) Set(ctx context.Context, in *pb.SetRequest) (*pb.SetResponse, error) {
+	group, key := in.GetGroup(), in.GetKey()
+	resp := &pb.SetResponse{}
+
+	if s.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(s.logger, "recv rpc set", logging.F("addr", s.addr), logging.Group(group), logging.KeyHash(key))
+	}
+	if key == "" {
+		return resp, fmt.Errorf("key required")
+	}
+	g := GetGroup(group)
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	var expire time.Time
+	if in.GetExpire() != 0 {
+		expire = time.Unix(0, in.GetExpire())
+	}
+	g.setLocally(key, NewByteView(in.GetValue(), expire).WithMeta(in.GetVersion(), in.GetFlags()))
+	return resp, nil
+}
+
+// Delete 实现cache service的Delete接口
+func (s *server) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	group, key := in.GetGroup(), in.GetKey()
+	resp := &pb.DeleteResponse{}
+
+	if s.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(s.logger, "recv rpc delete", logging.F("addr", s.addr), logging.Group(group), logging.KeyHash(key))
+	}
+	if key == "" {
+		return resp, fmt.Errorf("key required")
+	}
+	g := GetGroup(group)
+	if g == nil {
+		return resp, fmt.Errorf("group not found")
+	}
+	g.removeLocally(key)
 	return resp, nil
 }
 
+// Stats 实现cache service的Stats接口
+func (s *server) Stats(ctx context.Context, in *pb.StatsRequest) (*pb.StatsResponse, error) {
+	g := GetGroup(in.GetGroup())
+	if g == nil {
+		return &pb.StatsResponse{}, fmt.Errorf("group not found")
+	}
+	return g.Stats().toPB(), nil
+}
+
+// PeerStats 获取指定节点上group的统计信息 peerAddr为本节点时直接读取本地
+func (s *server) PeerStats(ctx context.Context, peerAddr string, group string) (GroupStats, error) {
+	if peerAddr == s.addr {
+		g := GetGroup(group)
+		if g == nil {
+			return GroupStats{}, fmt.Errorf("group not found")
+		}
+		return g.Stats(), nil
+	}
+	s.mu.Lock()
+	c, ok := s.clients[peerAddr]
+	s.mu.Unlock()
+	if !ok {
+		return GroupStats{}, fmt.Errorf("unknown peer %s", peerAddr)
+	}
+	return c.Stats(ctx, group)
+}
+
 // Start 启动cache服务
 func (s *server) Start() error {
 	s.mu.Lock()
@@ -95,75 +261,266 @@ func (s *server) Start() error {
 	// ----------------------------------------------
 	s.status = true
 	s.stopSignal = make(chan error)
+	s.done = make(chan struct{})
 
 	port := strings.Split(s.addr, ":")[1]
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
+		s.status = false
+		s.mu.Unlock()
 		return fmt.Errorf("failed to listen: %v", err)
 	}
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(metricsInterceptor, traceInterceptor))
 	pb.RegisterGroupCacheServer(grpcServer, s)
 
-	// 注册服务至etcd
+	// 启动指标http服务
+	if s.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", MetricsHandler())
+		s.metricsSvr = &http.Server{Addr: s.metricsAddr, Handler: mux}
+		go func(svr *http.Server) {
+			if err := svr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logging.Error(s.logger, "metrics server stopped", logging.F("addr", s.addr), logging.Err(err))
+			}
+		}(s.metricsSvr)
+	}
+
+	// 自动感知节点变化
+	if s.watchPeers > 0 {
+		if w, ok := s.discovery.(PeerWatcher); ok {
+			go s.watch(s.done, w, s.watchPeers)
+		} else {
+			logging.Warn(s.logger, "discovery does not support watching peers", logging.F("addr", s.addr))
+		}
+	}
+
+	// 注册服务 使用etcd时注册至etcd 静态发现时仅等待停止信号
+	if n, ok := s.discovery.(registry.StatusNotifier); ok {
+		n.SetStatusHandler(s.service, s.addr, s.statusChanged)
+	}
 	go func() {
 		// Register never return unless stop singnal received
-		err := registry.Register("peanutcache", s.addr, s.stopSignal)
+		// 注册失败或失去租约时会在后台重试 不会因为etcd短暂不可用而退出
+		err := s.discovery.Register(s.service, s.local, s.stopSignal)
 		if err != nil {
-			log.Fatalf(err.Error())
+			logging.Error(s.logger, "registration stopped", logging.F("addr", s.addr), logging.Err(err))
 		}
-		// Close channel
-		close(s.stopSignal)
 		// Close tcp listen
-		err = lis.Close()
-		if err != nil {
-			log.Fatalf(err.Error())
+		if err := lis.Close(); err != nil {
+			logging.Error(s.logger, "close listener failed", logging.F("addr", s.addr), logging.Err(err))
 		}
-		log.Printf("[%s] Revoke service and close tcp socket ok.", s.addr)
+		logging.Info(s.logger, "revoke service and close tcp socket ok", logging.F("addr", s.addr))
 	}()
 
+	// 定期关闭空闲的远程连接
+	go s.evictIdleClients(s.done)
+
 	//log.Printf("[%s] register service ok\n", s.addr)
 	s.mu.Unlock()
 
-	if err := grpcServer.Serve(lis); s.status && err != nil {
+	err = grpcServer.Serve(lis)
+	s.mu.Lock()
+	running := s.status
+	s.mu.Unlock()
+	if running && err != nil {
 		return fmt.Errorf("failed to serve: %v", err)
 	}
 	return nil
 }
 
+// statusChanged 记录本节点注册状态的变化并调用回调
+func (s *server) statusChanged(service string, addr string, status registry.Status, err error) {
+	if status == registry.StatusLost {
+		logging.Warn(s.logger, "registration lost, retrying", logging.F("service", service), logging.F("addr", addr), logging.Err(err))
+	} else {
+		logging.Info(s.logger, "registration status changed", logging.F("service", service), logging.F("addr", addr), logging.F("status", status))
+	}
+	s.statusMu.Lock()
+	fn := s.onStatus
+	s.statusMu.Unlock()
+	if fn != nil {
+		fn(status, err)
+	}
+}
+
 // SetPeers 将各个远端主机IP配置到Server里
 // 这样Server就可以Pick他们了
 // 注意: 此操作是*覆写*操作！
 // 注意: peersIP必须满足 x.x.x.x:port的格式
+// 远端节点的权重均为1 需要元数据时使用SetPeerEndpoints
 func (s *server) SetPeers(peersAddr ...string) {
+	peers := make([]registry.Endpoint, len(peersAddr))
+	for i, peerAddr := range peersAddr {
+		peers[i] = registry.Endpoint{Addr: peerAddr}
+	}
+	s.SetPeerEndpoints(peers...)
+}
+
+// SetPeerEndpoints 同SetPeers 并按节点元数据构建哈希环
+// 节点按权重分配虚拟节点 与本节点不兼容的节点被排除在外 本节点始终使用自己的元数据
+// 本节点设置了可用区时 同一可用区的节点另外组成一个哈希环 用于选择读副本
+func (s *server) SetPeerEndpoints(peers ...registry.Endpoint) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	for _, peer := range peers {
+		if !validPeerAddr(peer.Addr) {
+			panic(fmt.Sprintf("[peer %s] invalid address format, it should be x.x.x.x:port", peer.Addr))
+		}
+	}
 	s.consHash = consistenthash.New(defaultReplicas, nil)
-	s.consHash.Register(peersAddr...)
-	s.clients = make(map[string]*client)
-	for _, peerAddr := range peersAddr {
-		if !validPeerAddr(peerAddr) {
-			panic(fmt.Sprintf("[peer %s] invalid address format, it should be x.x.x.x:port", peerAddr))
+	s.zoneHash = nil
+	if s.local.Zone != "" {
+		s.zoneHash = consistenthash.New(defaultReplicas, nil)
+	}
+	s.peers = make(map[string]registry.Endpoint, len(peers))
+	for _, peer := range peers {
+		if peer.Addr == s.addr {
+			peer = s.local
+		} else if s.compatible != nil && !s.compatible(s.local, peer) {
+			logging.Warn(s.logger, "ignore incompatible peer", logging.F("addr", s.addr), logging.Peer(peer.Addr), logging.F("version", peer.Version))
+			continue
+		}
+		s.peers[peer.Addr] = peer
+		s.consHash.RegisterWeighted(peer.Addr, peer.Weight)
+		if s.zoneHash != nil && peer.Zone == s.local.Zone {
+			s.zoneHash.RegisterWeighted(peer.Addr, peer.Weight)
+		}
+	}
+	// 复用仍然存在的节点的连接 关闭已离开节点的连接 避免泄露
+	clients := make(map[string]*client, len(s.peers))
+	for peerAddr := range s.peers {
+		if c, ok := s.clients[peerAddr]; ok {
+			clients[peerAddr] = c
+			continue
+		}
+		clients[peerAddr] = newClient(s.service+"/"+peerAddr, peerAddr, s.discovery)
+	}
+	for peerAddr, c := range s.clients {
+		if _, ok := clients[peerAddr]; !ok {
+			c.close()
+		}
+	}
+	s.clients = clients
+}
+
+// watch 监听节点变化并在debounce时间内无新变化后重建哈希环 直到done被关闭
+func (s *server) watch(done chan struct{}, w PeerWatcher, debounce time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-done
+		cancel()
+	}()
+
+	// 只保留最新的节点列表
+	updates := make(chan []registry.Endpoint, 1)
+	go func() {
+		for {
+			err := w.WatchPeers(ctx, s.service, func(peers []registry.Endpoint) {
+				select {
+				case <-updates:
+				default:
+				}
+				updates <- peers
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			logging.Warn(s.logger, "watch peers failed", logging.F("addr", s.addr), logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRetryInterval):
+			}
+		}
+	}()
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+	var pending []registry.Endpoint
+	for {
+		select {
+		case <-done:
+			return
+		case peers := <-updates:
+			pending = peers
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(debounce)
+		case <-timer.C:
+			select {
+			case <-done: // server已停止 不再重建
+				return
+			default:
+			}
+			peers := make([]registry.Endpoint, 0, len(pending))
+			addrs := make([]string, 0, len(pending))
+			for _, peer := range pending {
+				if !validPeerAddr(peer.Addr) {
+					logging.Warn(s.logger, "ignore invalid peer address", logging.F("addr", s.addr), logging.Peer(peer.Addr))
+					continue
+				}
+				peers = append(peers, peer)
+				addrs = append(addrs, peer.Addr)
+			}
+			s.SetPeerEndpoints(peers...)
+			logging.Info(s.logger, "peers updated", logging.F("addr", s.addr), logging.F("peers", addrs))
 		}
-		service := fmt.Sprintf("gcache/%s", peerAddr)
-		s.clients[peerAddr] = NewClient(service)
 	}
 }
 
 // Pick 根据一致性哈希选举出key应存放在的cache
+// key的归属只由哈希环决定 与本节点的可用区无关 否则不同可用区的节点会将同一个key的读写发往不同节点
+// 可用区只用于选择读副本 见PickReplica
 // return false 代表从本地获取cache
 func (s *server) Pick(key string) (Fetcher, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.consHash == nil {
+		return nil, false
+	}
 	peerAddr := s.consHash.GetPeer(key)
 	// Pick itself
-	if peerAddr == s.addr {
-		log.Printf("ooh! pick myself, I am %s\n", s.addr)
+	c, ok := s.clients[peerAddr]
+	if peerAddr == s.addr || !ok {
+		return nil, false
+	}
+	if s.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(s.logger, "pick remote peer", logging.F("addr", s.addr), logging.Peer(peerAddr), logging.KeyHash(key))
+	}
+	return c, true
+}
+
+// PickReplica 为key选出本可用区内的读副本 key的归属节点在其他可用区时才有读副本
+// 读副本由同一可用区的节点组成的哈希环决定 同一可用区的节点对同一个key选出相同的读副本
+// return false 代表没有读副本 包括本节点即为读副本的情况 此时直接访问归属节点
+func (s *server) PickReplica(key string) (Fetcher, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.consHash == nil || s.zoneHash == nil {
+		return nil, false
+	}
+	owner := s.consHash.GetPeer(key)
+	if owner == s.addr || s.peers[owner].Zone == s.local.Zone {
 		return nil, false
 	}
-	log.Printf("[cache %s] pick remote peer: %s\n", s.addr, peerAddr)
-	return s.clients[peerAddr], true
+	replicaAddr := s.zoneHash.GetPeer(key)
+	c, ok := s.clients[replicaAddr]
+	if replicaAddr == s.addr || !ok {
+		return nil, false
+	}
+	if s.logger.Enabled(logging.LevelDebug) {
+		logging.Debug(s.logger, "pick zone replica", logging.F("addr", s.addr), logging.Peer(replicaAddr), logging.KeyHash(key))
+	}
+	return c, true
 }
 
 // Stop 停止server运行 如果server没有运行 这将是一个no-op
@@ -173,12 +530,48 @@ func (s *server) Stop() {
 		s.mu.Unlock()
 		return
 	}
-	s.stopSignal <- nil // 发送停止keepalive信号
-	s.status = false    // 设置server运行状态为stop
-	s.clients = nil     // 清空一致性哈希信息 有助于垃圾回收
+	close(s.done)    // 停止空闲连接清理
+	s.status = false // 设置server运行状态为stop
+	for _, c := range s.clients {
+		c.close()
+	}
+	s.clients = nil // 清空一致性哈希信息 有助于垃圾回收
 	s.consHash = nil
+	s.zoneHash = nil
+	s.peers = nil
+	if s.metricsSvr != nil {
+		s.metricsSvr.Close()
+		s.metricsSvr = nil
+	}
+	stopSignal, discovery := s.stopSignal, s.discovery
 	s.mu.Unlock()
+
+	// 释放锁之后再通知注册协程 注册过程中的状态回调可能需要获取锁
+	// 关闭而不是发送 注册协程正在注册时也不会阻塞
+	close(stopSignal) // 停止keepalive
+	discovery.Close()
+}
+
+// evictIdleClients 周期性关闭空闲超时的远程连接 直到done被关闭
+func (s *server) evictIdleClients(done chan struct{}) {
+	ticker := time.NewTicker(defaultIdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			for _, c := range s.clients {
+				c.closeIfIdle(defaultIdleTimeout)
+			}
+			s.mu.Unlock()
+		}
+	}
 }
 
-// 测试Server是否实现了Picker接口
-var _ Picker = (*server)(nil)
+// 测试Server是否实现了Picker和ReplicaPicker接口
+var (
+	_ Picker        = (*server)(nil)
+	_ ReplicaPicker = (*server)(nil)
+)
@@ -0,0 +1,639 @@
+package gcache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/juguagua/gCache/logging"
+	"github.com/juguagua/gCache/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// freeAddr 返回一个本地空闲地址
+func freeAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// waitListening 等待server开始监听addr
+func waitListening(t *testing.T, addr string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server %s not listening: %v", addr, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 测试静态服务发现 无需etcd即可启动server并连接节点
+func TestServer_StaticDiscovery(t *testing.T) {
+	NewGroup("static", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("static")
+
+	addr := freeAddr(t)
+	svr, err := NewServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr.SetDiscovery(NewStaticDiscovery())
+	svr.SetLogger(logging.Nop())
+	svr.SetPeers(addr)
+	started := make(chan error)
+	go func() {
+		started <- svr.Start()
+	}()
+
+	c := newClient("gcache/"+addr, addr, NewStaticDiscovery())
+	defer c.close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var view ByteView
+	for {
+		// 等待server开始监听
+		if view, err = c.Fetch(ctx, "static", "Tom"); err == nil || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || view.String() != "Tom" {
+		t.Fatalf("failed to fetch from static peer: %v", err)
+	}
+
+	svr.Stop()
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("server stopped with error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+// fakeWatcher 由测试控制节点列表的服务发现
+type fakeWatcher struct {
+	Discovery
+	updates chan []string
+}
+
+func (f *fakeWatcher) WatchPeers(ctx context.Context, service string, onChange func(peers []registry.Endpoint)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case addrs := <-f.updates:
+			peers := make([]registry.Endpoint, len(addrs))
+			for i, addr := range addrs {
+				peers[i] = registry.Endpoint{Addr: addr}
+			}
+			onChange(peers)
+		}
+	}
+}
+
+// 测试自动感知节点变化 变化合并后重建哈希环 并复用未变化节点的client
+func TestServer_PeerWatch(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &fakeWatcher{Discovery: NewStaticDiscovery(), updates: make(chan []string)}
+	svr.SetDiscovery(w)
+	svr.SetLogger(logging.Nop())
+	svr.SetPeerWatch(50 * time.Millisecond)
+	go svr.Start()
+	defer svr.Stop()
+
+	peers := func() map[string]*client {
+		svr.mu.Lock()
+		defer svr.mu.Unlock()
+		clients := make(map[string]*client, len(svr.clients))
+		for k, v := range svr.clients {
+			clients[k] = v
+		}
+		return clients
+	}
+	waitPeers := func(n int) map[string]*client {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if clients := peers(); len(clients) == n {
+				return clients
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expect %d peers, got %v", n, peers())
+		return nil
+	}
+
+	// 连续的变化只会在最后一次变化之后重建一次
+	w.updates <- []string{addr, "127.0.0.1:7001"}
+	w.updates <- []string{addr, "127.0.0.1:7001", "127.0.0.1:7002", "invalid"}
+	if clients := peers(); len(clients) != 0 {
+		t.Fatalf("ring should not be rebuilt before debounce, got %v", clients)
+	}
+	before := waitPeers(3)
+
+	w.updates <- []string{addr, "127.0.0.1:7001"}
+	after := waitPeers(2)
+	if after["127.0.0.1:7001"] != before["127.0.0.1:7001"] {
+		t.Fatal("client of unchanged peer should be reused")
+	}
+}
+
+// 测试多个server共享内存注册中心时自动发现彼此
+func TestServer_MemoryRegistry(t *testing.T) {
+	r := registry.NewMemory()
+	addrs := []string{freeAddr(t), freeAddr(t)}
+	var svrs []*server
+	for _, addr := range addrs {
+		svr, err := NewServer(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		svr.SetDiscovery(NewRegistryDiscovery(r))
+		svr.SetLogger(logging.Nop())
+		svr.SetPeerWatch(10 * time.Millisecond)
+		go svr.Start()
+		defer svr.Stop()
+		svrs = append(svrs, svr)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, svr := range svrs {
+		for {
+			svr.mu.Lock()
+			n := len(svr.clients)
+			svr.mu.Unlock()
+			if n == len(addrs) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("server %s found %d peers, expect %d", svr.addr, n, len(addrs))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// 测试注册和发现使用相同的服务名
+func TestServer_ServiceName(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServerWithOptions(addr, ServerOptions{ServiceName: "scores"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := registry.NewMemory()
+	svr.SetDiscovery(NewRegistryDiscovery(r))
+	svr.SetLogger(logging.Nop())
+	svr.SetPeerWatch(10 * time.Millisecond)
+	go svr.Start()
+	defer svr.Stop()
+
+	peer := freeAddr(t)
+	r.Register(context.Background(), "scores", registry.Endpoint{Addr: peer})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		svr.mu.Lock()
+		c, ok := svr.clients[peer]
+		svr.mu.Unlock()
+		if ok {
+			if c.name != "scores/"+peer {
+				t.Fatalf("unexpected client name %s", c.name)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("peer registered under service name not discovered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if eps, _ := r.List(context.Background(), "scores"); len(eps) != 2 {
+		t.Fatalf("expect server registered under service name, got %v", eps)
+	}
+}
+
+func TestServerOptions_Defaults(t *testing.T) {
+	opts := ServerOptions{Username: "root", Password: "secret"}.withDefaults()
+	if opts.ServiceName != defaultServiceName || opts.LeaseTTL != defaultLeaseTTL || opts.DialTimeout != defaultDialTimeout {
+		t.Fatalf("unexpected defaults %+v", opts)
+	}
+	cfg := opts.etcdConfig()
+	if len(cfg.Endpoints) != 1 || cfg.Username != "root" || cfg.Password != "secret" {
+		t.Fatalf("unexpected etcd config %+v", cfg)
+	}
+}
+
+// flakyRegistry 前几次注册失败的注册中心
+type flakyRegistry struct {
+	*registry.MemoryRegistry
+	failures int
+}
+
+func (r *flakyRegistry) Register(ctx context.Context, service string, ep registry.Endpoint) error {
+	if r.failures > 0 {
+		r.failures--
+		return fmt.Errorf("etcd unavailable")
+	}
+	return r.MemoryRegistry.Register(ctx, service, ep)
+}
+
+// 测试注册失败时server继续提供服务 并在重试成功后加入集群
+func TestServer_RegisterRetry(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &flakyRegistry{MemoryRegistry: registry.NewMemory(), failures: 2}
+	d := NewRegistryDiscovery(r).(*registryDiscovery)
+	d.backoff = registry.Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond}
+	svr.SetDiscovery(d)
+	svr.SetLogger(logging.Nop())
+	statuses := make(chan registry.Status, 10)
+	svr.SetStatusHandler(func(status registry.Status, err error) {
+		statuses <- status
+	})
+	go svr.Start()
+
+	for _, expect := range []registry.Status{registry.StatusLost, registry.StatusLost, registry.StatusRegistered} {
+		select {
+		case status := <-statuses:
+			if status != expect {
+				t.Fatalf("expect status %v, got %v", expect, status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expect status %v", expect)
+		}
+	}
+	if eps, _ := r.List(context.Background(), defaultServiceName); len(eps) != 1 || eps[0].Addr != addr {
+		t.Fatalf("expect server registered after retry, got %v", eps)
+	}
+	svr.Stop()
+	select {
+	case status := <-statuses:
+		if status != registry.StatusDeregistered {
+			t.Fatalf("expect deregistered, got %v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect deregistered after stop")
+	}
+}
+
+// slowRegistry 注册缓慢且总是失败的注册中心 模拟etcd不可用
+type slowRegistry struct {
+	*registry.MemoryRegistry
+}
+
+func (r *slowRegistry) Register(ctx context.Context, service string, ep registry.Endpoint) error {
+	time.Sleep(300 * time.Millisecond)
+	return fmt.Errorf("etcd unavailable")
+}
+
+// 测试注册重试期间停止server不会死锁
+func TestServer_StopWhileRegistering(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewRegistryDiscovery(&slowRegistry{MemoryRegistry: registry.NewMemory()}).(*registryDiscovery)
+	d.backoff = registry.Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond}
+	svr.SetDiscovery(d)
+	svr.SetLogger(logging.Nop())
+	svr.SetStatusHandler(func(status registry.Status, err error) {})
+	started := make(chan error)
+	go func() {
+		started <- svr.Start()
+	}()
+	waitListening(t, addr)
+	time.Sleep(100 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		svr.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stop blocked while registration is retrying")
+	}
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+// 测试节点元数据 权重决定分得的key 不兼容版本的节点被排除 可用区不影响key的归属
+func TestServer_SetPeerEndpoints(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServerWithOptions(addr, ServerOptions{Zone: "a", Version: "v1.2.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr.SetDiscovery(NewStaticDiscovery())
+	svr.SetLogger(logging.Nop())
+	svr.SetPeerEndpoints(
+		registry.Endpoint{Addr: addr},
+		registry.Endpoint{Addr: "127.0.0.1:7001", Zone: "a", Version: "v1.3.0", Weight: 4},
+		registry.Endpoint{Addr: "127.0.0.1:7002", Zone: "b", Version: "v1.1.0"},
+		registry.Endpoint{Addr: "127.0.0.1:7003", Zone: "a", Version: "v2.0.0"},
+	)
+	defer svr.SetPeers()
+
+	if _, ok := svr.clients["127.0.0.1:7003"]; ok {
+		t.Fatal("incompatible peer should not join the ring")
+	}
+	if len(svr.clients) != 3 || svr.peers[addr].Zone != "a" {
+		t.Fatalf("unexpected peers %v", svr.peers)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		owner := addr
+		if c, ok := svr.Pick(key); ok {
+			owner = c.(*client).addr
+		}
+		counts[owner]++
+		// 所有节点对key的归属一致 读写都发往哈希环上的同一个节点
+		if expect := svr.consHash.GetPeer(key); owner != expect {
+			t.Fatalf("key %s should be owned by %s, got %s", key, expect, owner)
+		}
+	}
+	if counts["127.0.0.1:7001"] < counts[addr] || counts["127.0.0.1:7001"] < counts["127.0.0.1:7002"] {
+		t.Fatalf("heavier peer should own most keys, got %v", counts)
+	}
+
+	svr.SetCompatibility(nil)
+	svr.SetPeerEndpoints(registry.Endpoint{Addr: addr}, registry.Endpoint{Addr: "127.0.0.1:7003", Version: "v2.0.0"})
+	if _, ok := svr.clients["127.0.0.1:7003"]; !ok {
+		t.Fatal("peer should join the ring without compatibility check")
+	}
+}
+
+// 测试归属节点在其他可用区时选出本可用区的读副本 key的归属不变
+func TestServer_PickReplica(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServerWithOptions(addr, ServerOptions{Zone: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr.SetDiscovery(NewStaticDiscovery())
+	svr.SetLogger(logging.Nop())
+	svr.SetPeerEndpoints(
+		registry.Endpoint{Addr: addr},
+		registry.Endpoint{Addr: "127.0.0.1:7001", Zone: "a"},
+		registry.Endpoint{Addr: "127.0.0.1:7002", Zone: "b"},
+	)
+	defer svr.SetPeers()
+
+	replicas := 0
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		owner := svr.consHash.GetPeer(key)
+		c, ok := svr.PickReplica(key)
+		if owner != "127.0.0.1:7002" {
+			if ok {
+				t.Fatalf("key %s owned by same zone peer %s should not have a replica", key, owner)
+			}
+			continue
+		}
+		// 同一可用区的节点选出相同的读副本 本节点即为读副本时直接访问归属节点
+		expect := svr.zoneHash.GetPeer(key)
+		if expect == addr {
+			if ok {
+				t.Fatalf("key %s: local node is the replica, got %s", key, c.(*client).addr)
+			}
+			continue
+		}
+		if !ok || c.(*client).addr != expect {
+			t.Fatalf("key %s should be read from replica %s", key, expect)
+		}
+		replicas++
+	}
+	if replicas == 0 {
+		t.Fatal("expect some keys to be read from the zone replica")
+	}
+
+	// 未设置可用区时没有读副本
+	svr.local.Zone = ""
+	svr.SetPeerEndpoints(registry.Endpoint{Addr: addr}, registry.Endpoint{Addr: "127.0.0.1:7002", Zone: "b"})
+	for i := 0; i < 100; i++ {
+		if _, ok := svr.PickReplica(strconv.Itoa(i)); ok {
+			t.Fatal("expect no replica without zone")
+		}
+	}
+}
+
+func TestSameMajorVersion(t *testing.T) {
+	cases := []struct {
+		local, peer string
+		expect      bool
+	}{
+		{"v1.2.3", "v1.0.0", true},
+		{"v1.2.3", "1.9", true},
+		{"v1.2.3", "v2.0.0", false},
+		{"", "v2.0.0", true},
+		{"v1.2.3", "", true},
+	}
+	for _, c := range cases {
+		if got := SameMajorVersion(registry.Endpoint{Version: c.local}, registry.Endpoint{Version: c.peer}); got != c.expect {
+			t.Errorf("SameMajorVersion(%s, %s) = %v, expect %v", c.local, c.peer, got, c.expect)
+		}
+	}
+}
+
+// 测试SetPeers关闭已离开节点的连接 Stop关闭所有连接
+func TestServer_CloseClients(t *testing.T) {
+	addr := freeAddr(t)
+	svr, err := NewServer(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svr.SetDiscovery(NewStaticDiscovery())
+	svr.SetLogger(logging.Nop())
+	started := make(chan error)
+	go func() {
+		started <- svr.Start()
+	}()
+	waitListening(t, addr)
+
+	svr.SetPeers(addr, "127.0.0.1:7001", "127.0.0.1:7002")
+	conns := make(map[string]*grpc.ClientConn)
+	for peer, c := range svr.clients {
+		if conns[peer], err = c.dial(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svr.SetPeers(addr, "127.0.0.1:7001")
+	if conns["127.0.0.1:7002"].GetState() != connectivity.Shutdown {
+		t.Fatal("conn of removed peer should be closed")
+	}
+	if conns["127.0.0.1:7001"].GetState() == connectivity.Shutdown {
+		t.Fatal("conn of remaining peer should be kept")
+	}
+
+	svr.Stop()
+	for peer, conn := range conns {
+		if conn.GetState() != connectivity.Shutdown {
+			t.Fatalf("conn of %s should be closed after stop", peer)
+		}
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+// loggerRegistry 记录所设置日志的注册中心
+type loggerRegistry struct {
+	*registry.MemoryRegistry
+	logger logging.Logger
+}
+
+func (r *loggerRegistry) SetLogger(logger logging.Logger) {
+	r.logger = logger
+}
+
+// blockingRegistry 注册一直阻塞直到ctx被取消的注册中心 模拟etcd不可用时客户端等待连接就绪
+type blockingRegistry struct {
+	*registry.MemoryRegistry
+}
+
+func (r *blockingRegistry) Register(ctx context.Context, service string, ep registry.Endpoint) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// 测试注册一直阻塞时单次注册超时后报告StatusLost 停止server时取消正在进行的注册
+func TestServer_StopWhileRegisterBlocked(t *testing.T) {
+	start := func(timeout time.Duration) (*server, chan registry.Status, chan error) {
+		addr := freeAddr(t)
+		svr, err := NewServer(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewRegistryDiscovery(&blockingRegistry{MemoryRegistry: registry.NewMemory()}).(*registryDiscovery)
+		d.backoff = registry.Backoff{Min: time.Millisecond, Max: 10 * time.Millisecond}
+		d.timeout = timeout
+		svr.SetDiscovery(d)
+		svr.SetLogger(logging.Nop())
+		statuses := make(chan registry.Status, 100)
+		svr.SetStatusHandler(func(status registry.Status, err error) {
+			statuses <- status
+		})
+		started := make(chan error)
+		go func() {
+			started <- svr.Start()
+		}()
+		waitListening(t, addr)
+		return svr, statuses, started
+	}
+
+	svr, statuses, started := start(50 * time.Millisecond)
+	select {
+	case status := <-statuses:
+		if status != registry.StatusLost {
+			t.Fatalf("expect status lost, got %v", status)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("blocked registration should time out and report lost")
+	}
+	svr.Stop()
+	<-started
+
+	// 单次注册不会超时 只有停止才能使其返回
+	svr, _, started = start(time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	stopped := make(chan struct{})
+	go func() {
+		svr.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("stop blocked while registration is blocked")
+	}
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("blocked registration should be canceled on stop")
+	}
+}
+
+// 测试server的日志同时作用于其注册中心
+func TestServer_SetLoggerRegistry(t *testing.T) {
+	svr, err := NewServer(freeAddr(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &loggerRegistry{MemoryRegistry: registry.NewMemory()}
+	svr.SetDiscovery(NewRegistryDiscovery(r))
+	svr.SetLogger(logging.Nop())
+	if r.logger != logging.Nop() {
+		t.Fatalf("logger should be forwarded to the registry, got %v", r.logger)
+	}
+}
+
+// 测试共享同一个注册中心的多个server各自收到自己的注册状态
+func TestServer_StatusHandlerSharedRegistry(t *testing.T) {
+	r := registry.NewMemory()
+	var servers []*server
+	var statuses []chan registry.Status
+	for i := 0; i < 2; i++ {
+		addr := freeAddr(t)
+		svr, err := NewServer(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		svr.SetDiscovery(NewRegistryDiscovery(r))
+		svr.SetLogger(logging.Nop())
+		ch := make(chan registry.Status, 10)
+		svr.SetStatusHandler(func(status registry.Status, err error) {
+			ch <- status
+		})
+		go svr.Start()
+		waitListening(t, addr)
+		servers, statuses = append(servers, svr), append(statuses, ch)
+	}
+	expect := func(i int, want registry.Status) {
+		t.Helper()
+		select {
+		case status := <-statuses[i]:
+			if status != want {
+				t.Fatalf("server %d: expect status %v, got %v", i, want, status)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("server %d: expect status %v", i, want)
+		}
+	}
+	for i := range servers {
+		expect(i, registry.StatusRegistered)
+	}
+	for i, svr := range servers {
+		svr.Stop()
+		expect(i, registry.StatusDeregistered)
+	}
+}
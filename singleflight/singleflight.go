@@ -1,6 +1,10 @@
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 // single flight 为cache提供缓存击穿的保护
 // 当cache并发访问peer获取缓存时 如果peer未缓存该值
@@ -10,9 +14,10 @@ import "sync"
 // flight载有我们要的缓存数据 称为packet
 
 type packet struct {
-	wg  sync.WaitGroup
-	val interface{}
-	err error
+	done chan struct{} // flight完成后关闭
+	val  interface{}
+	err  error
+	ctx  *flightContext // fn的ctx 记录仍在等待结果的调用者
 }
 
 type Flight struct {
@@ -21,27 +26,118 @@ type Flight struct {
 }
 
 // Fly 负责key从数据源进行获取 fn是获取packet的方法
-func (f *Flight) Fly(key string, fn func() (interface{}, error)) (interface{}, error) {
+// fn在独立的协程中执行 其ctx保留首个调用者ctx中的值(如trace) 但不受任何单个调用者取消的影响
+// fn的ctx的deadline为仍在等待的调用者中最晚的deadline 所有调用者都没有deadline时没有deadline
+// 调用者的ctx被取消时提前返回ctx.Err() 其他调用者继续等待结果
+// 所有调用者都放弃等待时fn的ctx被取消 因此加载最多持续到最后一个调用者的deadline
+func (f *Flight) Fly(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
 	f.mu.Lock()
 	if f.flight == nil {
 		f.flight = make(map[string]*packet)
 	}
-	if p, ok := f.flight[key]; ok {
-		f.mu.Unlock()
-		p.wg.Wait() // 等待协程结束
-		return p.val, p.err
+	p, ok := f.flight[key]
+	if !ok {
+		p = &packet{done: make(chan struct{}), ctx: newFlightContext(ctx)}
+		f.flight[key] = p
+	}
+	w := p.ctx.join(ctx) // 起飞前加入 fn总能看到首个调用者的deadline
+	if !ok {
+		go f.fly(key, p, fn)
 	}
-	p := new(packet)
-	p.wg.Add(1)
-	f.flight[key] = p
 	f.mu.Unlock()
 
-	p.val, p.err = fn()
-	p.wg.Done()
+	select { // 等待协程结束
+	case <-p.done:
+		return p.val, p.err
+	case <-ctx.Done():
+		f.mu.Lock()
+		if p.ctx.leave(w) == 0 { // 无人等待 取消加载 之后的调用者重新起飞
+			p.ctx.cancel()
+			if f.flight[key] == p {
+				delete(f.flight, key)
+			}
+		}
+		f.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (f *Flight) fly(key string, p *packet, fn func(ctx context.Context) (interface{}, error)) {
+	defer p.ctx.cancel()
+	p.val, p.err = fn(p.ctx)
 
 	f.mu.Lock()
-	delete(f.flight, key) // 航班已完成
+	if f.flight[key] == p {
+		delete(f.flight, key) // 航班已完成
+	}
 	f.mu.Unlock()
+	close(p.done)
+}
+
+// waiter 一个等待结果的调用者
+type waiter struct {
+	ctx context.Context
+}
+
+// flightContext fn使用的ctx 保留首个调用者ctx中的值 但不继承其取消
+// deadline随调用者的加入和离开变化 取当前等待者中最晚的deadline
+type flightContext struct {
+	values context.Context // 首个调用者的ctx 只用于读取值
+	done   chan struct{}
+
+	mu      sync.Mutex
+	waiters map[*waiter]struct{}
+	err     error
+}
 
-	return p.val, p.err
+func newFlightContext(ctx context.Context) *flightContext {
+	return &flightContext{values: ctx, done: make(chan struct{}), waiters: make(map[*waiter]struct{})}
 }
+
+// join 加入一个等待者
+func (c *flightContext) join(ctx context.Context) *waiter {
+	w := &waiter{ctx: ctx}
+	c.mu.Lock()
+	c.waiters[w] = struct{}{}
+	c.mu.Unlock()
+	return w
+}
+
+// leave 移除一个等待者 返回剩余的等待者数量
+func (c *flightContext) leave(w *waiter) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.waiters, w)
+	return len(c.waiters)
+}
+
+func (c *flightContext) cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = context.Canceled
+		close(c.done)
+	}
+}
+
+// Deadline 返回等待者中最晚的deadline 没有deadline的等待者不参与比较
+func (c *flightContext) Deadline() (deadline time.Time, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for w := range c.waiters {
+		if d, has := w.ctx.Deadline(); has && (!ok || d.After(deadline)) {
+			deadline, ok = d, true
+		}
+	}
+	return deadline, ok
+}
+
+func (c *flightContext) Done() <-chan struct{} { return c.done }
+
+func (c *flightContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *flightContext) Value(key interface{}) interface{} { return c.values.Value(key) }
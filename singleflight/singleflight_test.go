@@ -0,0 +1,148 @@
+package singleflight
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFly(t *testing.T) {
+	var f Flight
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := f.Fly(context.Background(), "foo", fn); err != nil || v != "bar" {
+				t.Errorf("expect bar, got %v %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expect 1 call, got %d", n)
+	}
+}
+
+// 测试首个调用者取消后 其他调用者仍能得到结果
+func TestFly_FirstCallerCanceled(t *testing.T) {
+	var f Flight
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return "bar", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := f.Fly(ctx, "foo", fn)
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	second := make(chan interface{})
+	go func() {
+		v, _ := f.Fly(context.Background(), "foo", fn)
+		second <- v
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("first caller should be canceled, got %v", err)
+	}
+	close(release)
+	if v := <-second; v != "bar" {
+		t.Fatalf("second caller should get bar, got %v", v)
+	}
+}
+
+type traceKey struct{}
+
+// 测试所有调用者都放弃等待后取消加载 之后的调用者重新加载
+func TestFly_AllCallersCanceled(t *testing.T) {
+	var f Flight
+	canceled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "t1"))
+	go cancel()
+	_, err := f.Fly(ctx, "foo", func(ctx context.Context) (interface{}, error) {
+		if ctx.Value(traceKey{}) != "t1" {
+			t.Errorf("values of caller ctx should be kept")
+		}
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	})
+	if err != context.Canceled {
+		t.Fatalf("expect canceled, got %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("load should be canceled when nobody waits")
+	}
+
+	v, err := f.Fly(context.Background(), "foo", func(ctx context.Context) (interface{}, error) {
+		return "bar", nil
+	})
+	if err != nil || v != "bar" {
+		t.Fatalf("expect new flight, got %v %v", v, err)
+	}
+}
+
+// 测试fn的ctx取等待者中最晚的deadline
+func TestFly_Deadline(t *testing.T) {
+	var f Flight
+	release := make(chan struct{})
+	deadlines := make(chan time.Time, 2)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-release
+		d, _ := ctx.Deadline()
+		deadlines <- d
+		return "bar", nil
+	}
+
+	early, late := time.Now().Add(time.Minute), time.Now().Add(time.Hour)
+	ctx1, cancel1 := context.WithDeadline(context.Background(), early)
+	defer cancel1()
+	ctx2, cancel2 := context.WithDeadline(context.Background(), late)
+	defer cancel2()
+	var wg sync.WaitGroup
+	for _, ctx := range []context.Context{ctx1, context.Background(), ctx2} {
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			f.Fly(ctx, "foo", fn)
+		}(ctx)
+		time.Sleep(20 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if d := <-deadlines; !d.Equal(late) {
+		t.Fatalf("expect latest deadline %v, got %v", late, d)
+	}
+
+	// 没有调用者设置deadline时fn的ctx也没有deadline
+	f.Fly(context.Background(), "foo", func(ctx context.Context) (interface{}, error) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Errorf("expect no deadline")
+		}
+		return nil, nil
+	})
+}
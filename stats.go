@@ -0,0 +1,178 @@
+package gcache
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	pb "github.com/juguagua/gCache/gcachepb"
+)
+
+// stats 模块提供Group和cache的统计信息
+
+// AtomicInt 可以被原子操作的int64计数器
+type AtomicInt int64
+
+// Add 原子地增加n
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get 原子地读取当前值
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// groupStats Group的计数器
+type groupStats struct {
+	gets           AtomicInt // 所有的Get请求 包括来自远程节点的请求
+	mainCacheHits  AtomicInt // 主缓存命中次数
+	hotCacheHits   AtomicInt // 热点缓存命中次数
+	loads          AtomicInt // 缓存未命中需要加载的次数 (gets - hits)
+	loadsExecuted  AtomicInt // 经过singleflight去重后实际执行的加载次数
+	peerLoads      AtomicInt // 从远程节点加载成功的次数
+	peerErrors     AtomicInt // 从远程节点加载失败的次数
+	localLoads     AtomicInt // 从本地getter加载成功的次数
+	localLoadErrs  AtomicInt // 从本地getter加载失败的次数
+	serverRequests AtomicInt // 来自远程节点的Get请求
+}
+
+// GroupStats Group统计信息的快照
+type GroupStats struct {
+	Gets           int64
+	MainCacheHits  int64
+	HotCacheHits   int64
+	Loads          int64
+	LoadsExecuted  int64 // 经过singleflight去重后实际执行的加载次数
+	FlightDedups   int64 // 被singleflight合并的加载次数 (Loads - LoadsExecuted)
+	PeerLoads      int64
+	PeerErrors     int64
+	LocalLoads     int64
+	LocalLoadErrs  int64
+	ServerRequests int64
+	MainCache      CacheStats
+	HotCache       CacheStats
+}
+
+// CacheStats cache统计信息的快照
+type CacheStats struct {
+	Bytes        int64 // 计入容量限制的字节数
+	Items        int64 // 数据数量
+	Gets         int64 // 查询次数
+	Hits         int64 // 命中次数
+	Evictions    int64 // 超出容量被淘汰的数量
+	Expirations  int64 // 过期被移除的数量
+	Removals     int64 // 被主动删除的数量
+	Replacements int64 // 被新的value覆盖的数量
+}
+
+// Stats 返回Group的统计信息
+func (g *Group) Stats() GroupStats {
+	stats := GroupStats{
+		Gets:           g.stats.gets.Get(),
+		MainCacheHits:  g.stats.mainCacheHits.Get(),
+		HotCacheHits:   g.stats.hotCacheHits.Get(),
+		Loads:          g.stats.loads.Get(),
+		LoadsExecuted:  g.stats.loadsExecuted.Get(),
+		PeerLoads:      g.stats.peerLoads.Get(),
+		PeerErrors:     g.stats.peerErrors.Get(),
+		LocalLoads:     g.stats.localLoads.Get(),
+		LocalLoadErrs:  g.stats.localLoadErrs.Get(),
+		ServerRequests: g.stats.serverRequests.Get(),
+		MainCache:      g.mainCache.stats(),
+	}
+	stats.FlightDedups = stats.Loads - stats.LoadsExecuted
+	if g.hotCache != nil {
+		stats.HotCache = g.hotCache.stats()
+	}
+	return stats
+}
+
+// stats 返回cache的统计信息
+func (c *cache) stats() CacheStats {
+	c.init()
+	stats := CacheStats{
+		Gets:         c.gets.Get(),
+		Hits:         c.hits.Get(),
+		Evictions:    c.evictions[EvictCapacity].Get(),
+		Expirations:  c.evictions[EvictExpired].Get(),
+		Removals:     c.evictions[EvictRemoved].Get(),
+		Replacements: c.evictions[EvictReplaced].Get(),
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		if s.policy != nil {
+			stats.Bytes += int64(s.policy.Bytes())
+			stats.Items += int64(s.policy.Len())
+		}
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// toPB 将统计信息转换为rpc响应
+func (s GroupStats) toPB() *pb.StatsResponse {
+	return &pb.StatsResponse{
+		Gets:           s.Gets,
+		MainCacheHits:  s.MainCacheHits,
+		HotCacheHits:   s.HotCacheHits,
+		Loads:          s.Loads,
+		LoadsExecuted:  s.LoadsExecuted,
+		PeerLoads:      s.PeerLoads,
+		PeerErrors:     s.PeerErrors,
+		LocalLoads:     s.LocalLoads,
+		LocalLoadErrs:  s.LocalLoadErrs,
+		ServerRequests: s.ServerRequests,
+		MainCache:      s.MainCache.toPB(),
+		HotCache:       s.HotCache.toPB(),
+	}
+}
+
+func (s CacheStats) toPB() *pb.CacheStats {
+	return &pb.CacheStats{
+		Bytes:        s.Bytes,
+		Items:        s.Items,
+		Gets:         s.Gets,
+		Hits:         s.Hits,
+		Evictions:    s.Evictions,
+		Expirations:  s.Expirations,
+		Removals:     s.Removals,
+		Replacements: s.Replacements,
+	}
+}
+
+// groupStatsFromPB 将rpc响应转换为统计信息
+func groupStatsFromPB(resp *pb.StatsResponse) GroupStats {
+	s := GroupStats{
+		Gets:           resp.GetGets(),
+		MainCacheHits:  resp.GetMainCacheHits(),
+		HotCacheHits:   resp.GetHotCacheHits(),
+		Loads:          resp.GetLoads(),
+		LoadsExecuted:  resp.GetLoadsExecuted(),
+		PeerLoads:      resp.GetPeerLoads(),
+		PeerErrors:     resp.GetPeerErrors(),
+		LocalLoads:     resp.GetLocalLoads(),
+		LocalLoadErrs:  resp.GetLocalLoadErrs(),
+		ServerRequests: resp.GetServerRequests(),
+		MainCache:      cacheStatsFromPB(resp.GetMainCache()),
+		HotCache:       cacheStatsFromPB(resp.GetHotCache()),
+	}
+	s.FlightDedups = s.Loads - s.LoadsExecuted
+	return s
+}
+
+func cacheStatsFromPB(c *pb.CacheStats) CacheStats {
+	return CacheStats{
+		Bytes:        c.GetBytes(),
+		Items:        c.GetItems(),
+		Gets:         c.GetGets(),
+		Hits:         c.GetHits(),
+		Evictions:    c.GetEvictions(),
+		Expirations:  c.GetExpirations(),
+		Removals:     c.GetRemovals(),
+		Replacements: c.GetReplacements(),
+	}
+}
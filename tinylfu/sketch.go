@@ -0,0 +1,92 @@
+package tinylfu
+
+import "hash/fnv"
+
+// sketch Count-Min Sketch，用较小的固定内存估算每个key的访问频次
+// 每个计数器最大为15(与4bit计数器一致)，累计增加次数达到sampleSize后所有计数器减半，
+// 使频次估算能够反映最近的访问情况
+
+const (
+	sketchDepth = 4
+	maxCounter  = 15
+)
+
+var sketchSeeds = [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+type sketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64 // 计数器数量为2的幂 用于取模
+	additions  int    // 距离上次减半累计的增加次数
+	sampleSize int    // 达到该次数后进行减半
+}
+
+// newSketch 创建至少包含counters个计数器的sketch
+func newSketch(counters int) *sketch {
+	width := 1
+	for width < counters {
+		width <<= 1
+	}
+	s := &sketch{
+		mask:       uint64(width - 1),
+		sampleSize: 10 * width,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// 每一行使用不同的种子对hash进行再散列
+func (s *sketch) index(h uint64, row int) uint64 {
+	h ^= sketchSeeds[row]
+	h *= 0x9e3779b97f4a7c15
+	h ^= h >> 32
+	return h & s.mask
+}
+
+// increment 增加key的频次
+func (s *sketch) increment(key string) {
+	h := hashKey(key)
+	added := false
+	for i := range s.rows {
+		idx := s.index(h, i)
+		if s.rows[i][idx] < maxCounter {
+			s.rows[i][idx]++
+			added = true
+		}
+	}
+	if added {
+		s.additions++
+		if s.additions >= s.sampleSize {
+			s.reset()
+		}
+	}
+}
+
+// estimate 估算key的频次 取所有行中的最小值
+func (s *sketch) estimate(key string) uint8 {
+	h := hashKey(key)
+	min := uint8(maxCounter)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// reset 所有计数器减半 使历史访问频次逐渐衰减
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
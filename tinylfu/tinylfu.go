@@ -0,0 +1,443 @@
+package tinylfu
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/juguagua/gCache/lru"
+	"github.com/juguagua/gCache/zset"
+)
+
+// W-TinyLFU：由一个小的LRU窗口和一个分段LRU(SLRU)主区域组成。
+// 新数据先进入窗口，从窗口淘汰的数据作为候选者，与主区域即将被淘汰的数据比较
+// Count-Min Sketch估算的访问频次，频次更高者留在缓存中。
+// 窗口使突发的新数据有机会积累频次，频次准入则使偶发访问不会挤掉真正的热点数据。
+// 主区域分为试用区(probation)和保护区(protected)，试用区的数据再次命中后才会进入保护区。
+
+// Warning: tinylfu包不提供并发一致机制
+
+const (
+	expiresZSetKey = ""
+	// 每次移除过期键数量
+	removeExpireN = 10
+
+	windowPercent    = 1  // 窗口占总容量的百分比
+	maxWindowPercent = 20 // 窗口不足minWindowBytes时最多占总容量的百分比
+	protectedPercent = 80 // 保护区占主区域的百分比
+
+	// 窗口的最小容量 约可存放16个平均大小的数据
+	// 分片后每个分片的容量可能只有几KB 按比例计算的窗口放不下任何数据 新数据将直接参与准入
+	minWindowBytes = 16 * avgEntryBytes
+
+	avgEntryBytes   = 64 // 用于根据容量估算数据数量
+	minCounters     = 1 << 10
+	maxCounters     = 1 << 22
+	defaultCounters = 1 << 16 // 无容量限制时使用的计数器数量
+)
+
+type regionID int
+
+const (
+	window regionID = iota
+	probation
+	protected
+)
+
+// region 一个按LRU排序的区域 链表头部为最近访问的数据
+type region struct {
+	ll       *list.List
+	length   int // 当前大小
+	capacity int // 容量 为0时表示无限制
+}
+
+func (r *region) full(extra int) bool {
+	return r.capacity != 0 && r.length+extra > r.capacity
+}
+
+// entry 定义双向链表节点所存储的对象
+type entry struct {
+	key    string
+	value  lru.Value
+	size   int // 计入缓存大小的字节数
+	region regionID
+}
+
+// Stats 命中率统计
+type Stats struct {
+	Hits     int64 // 命中次数
+	Misses   int64 // 未命中次数
+	Admitted int64 // 候选者被主区域接纳的次数
+	Rejected int64 // 候选者被拒绝的次数
+}
+
+// HitRatio 返回命中率 没有请求时为0
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Cache W-TinyLFU缓存
+type Cache struct {
+	capacity        int  // 缓存容量
+	maxEntries      int  // 最大数据数量 为0时不限制
+	accountOverhead bool // 是否将每个entry的额外开销计入缓存大小
+	regions         [3]*region
+	cache           map[string]*list.Element
+	sketch          *sketch
+	onEvicted       lru.OnEvicted   // 可选，在entry被移除的时候执行
+	expires         *zset.SortedSet // 过期键集合
+	stats           Stats
+}
+
+// New 创建指定最大容量的W-TinyLFU缓存。
+// 当maxBytes为0时，代表cache无内存限制，无限存放
+func New(maxBytes int, onEvicted lru.OnEvicted) *Cache {
+	counters := defaultCounters
+	if maxBytes != 0 {
+		counters = maxBytes / avgEntryBytes
+		if counters < minCounters {
+			counters = minCounters
+		}
+		if counters > maxCounters {
+			counters = maxCounters
+		}
+	}
+	return NewWithCounters(maxBytes, counters, onEvicted)
+}
+
+// NewWithCounters 同New 可以指定频次估算使用的计数器数量，通常取预计的数据数量
+func NewWithCounters(maxBytes int, counters int, onEvicted lru.OnEvicted) *Cache {
+	c := &Cache{
+		regions: [3]*region{
+			window:    {ll: list.New()},
+			probation: {ll: list.New()},
+			protected: {ll: list.New()},
+		},
+		cache:     make(map[string]*list.Element),
+		sketch:    newSketch(counters),
+		onEvicted: onEvicted,
+		expires:   zset.New(),
+	}
+	c.setCapacity(maxBytes)
+	return c
+}
+
+// SetCapacity 调整缓存容量并按比例调整各区域的容量，容量变小时立即淘汰数据，为0时不限制
+func (c *Cache) SetCapacity(maxBytes int) {
+	c.setCapacity(maxBytes)
+	c.evict()
+}
+
+// SetMaxEntries 设置最大数据数量，超出后淘汰窗口候选者和主区域淘汰者中访问频次较低的一个，为0时不限制
+func (c *Cache) SetMaxEntries(n int) {
+	c.maxEntries = n
+	c.evict()
+}
+
+// SetAccountOverhead 设置是否将每个entry的估算额外开销计入缓存大小
+// 开启后容量限制更接近实际的堆内存占用
+func (c *Cache) SetAccountOverhead(account bool) {
+	c.accountOverhead = account
+	for _, r := range c.regions {
+		for e := r.ll.Front(); e != nil; e = e.Next() {
+			ent := e.Value.(*entry)
+			size := c.size(ent.key, ent.value)
+			r.length += size - ent.size
+			ent.size = size
+		}
+	}
+	c.evict()
+}
+
+func (c *Cache) setCapacity(maxBytes int) {
+	windowBytes := maxBytes * windowPercent / 100
+	if windowBytes < minWindowBytes {
+		windowBytes = minWindowBytes
+		if max := maxBytes * maxWindowPercent / 100; windowBytes > max {
+			windowBytes = max
+		}
+	}
+	if maxBytes != 0 && windowBytes == 0 {
+		windowBytes = 1
+	}
+	mainBytes := maxBytes - windowBytes
+	protectedBytes := mainBytes * protectedPercent / 100
+	c.capacity = maxBytes
+	c.regions[window].capacity = windowBytes
+	c.regions[probation].capacity = mainBytes - protectedBytes
+	c.regions[protected].capacity = protectedBytes
+}
+
+// Get 从缓存获取对应key的value
+func (c *Cache) Get(key string) (value lru.Value, ok bool) {
+	c.sketch.increment(key)
+	element, ok := c.cache[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	ent := element.Value.(*entry)
+	// 移除过期的键
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		c.removeElement(element, lru.EvictExpired)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.touch(element)
+	return ent.value, true
+}
+
+// Peek 获取对应key的value 不计入访问频次和命中率 已过期的value视为不存在但不会被移除
+func (c *Cache) Peek(key string) (value lru.Value, ok bool) {
+	element, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	ent := element.Value.(*entry)
+	if !ent.value.Expire().IsZero() && ent.value.Expire().Before(time.Now()) {
+		return nil, false
+	}
+	return ent.value, true
+}
+
+// Add 添加数据到缓存
+func (c *Cache) Add(key string, value lru.Value) {
+	if element, ok := c.cache[key]; ok {
+		ent := element.Value.(*entry)
+		size := c.size(key, value)
+		c.regions[ent.region].length += size - ent.size
+		old := ent.value
+		ent.value, ent.size = value, size
+		c.touch(element)
+		if c.onEvicted != nil {
+			c.onEvicted(key, old, lru.EvictReplaced)
+		}
+	} else {
+		ent := &entry{key: key, value: value, size: c.size(key, value), region: window}
+		c.push(ent)
+	}
+	// 如果有超时时间则设置
+	if !value.Expire().IsZero() {
+		c.expires.ZAdd(expiresZSetKey, value.Expire().UnixNano(), key)
+	} else {
+		// 没有则删除
+		c.expires.ZRem(expiresZSetKey, key)
+	}
+	// 淘汰过期的key
+	if c.capacity != 0 {
+		c.removeExpire(removeExpireN)
+	}
+	c.evict()
+}
+
+// Remove 移除某个键
+func (c *Cache) Remove(key string) {
+	if element, ok := c.cache[key]; ok {
+		c.removeElement(element, lru.EvictRemoved)
+	}
+}
+
+// Len 返回数据数量
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes 返回当前缓存占用的字节数，开启额外开销统计时包含估算的额外开销
+func (c *Cache) Bytes() int {
+	return c.regions[window].length + c.regions[probation].length + c.regions[protected].length
+}
+
+// LogicalBytes 返回key和value的字节数
+func (c *Cache) LogicalBytes() int {
+	if c.accountOverhead {
+		return c.Bytes() - c.overhead()
+	}
+	return c.Bytes()
+}
+
+// EstimatedBytes 返回包含链表节点、map、过期集合等额外开销的估算字节数 不包含频次估算的计数器
+func (c *Cache) EstimatedBytes() int {
+	return c.LogicalBytes() + c.overhead()
+}
+
+// 缓存中数据的估算额外开销
+func (c *Cache) overhead() int {
+	return c.Len()*lru.EntryOverhead + c.expires.ZCard(expiresZSetKey)*lru.ExpireOverhead
+}
+
+// 数据计入缓存大小的字节数
+func (c *Cache) size(key string, value lru.Value) int {
+	if c.accountOverhead {
+		return len(key) + value.Len() + lru.Overhead(value)
+	}
+	return len(key) + value.Len()
+}
+
+// Stats 返回命中率统计
+func (c *Cache) Stats() Stats {
+	return c.stats
+}
+
+// 将数据放入所属区域的头部
+func (c *Cache) push(ent *entry) {
+	r := c.regions[ent.region]
+	c.cache[ent.key] = r.ll.PushFront(ent)
+	r.length += ent.size
+}
+
+// 将数据从所属区域摘除
+func (c *Cache) detach(e *list.Element) *entry {
+	ent := e.Value.(*entry)
+	r := c.regions[ent.region]
+	r.ll.Remove(e)
+	r.length -= ent.size
+	return ent
+}
+
+// 数据被访问 试用区的数据晋升至保护区 其他区域移动到头部
+func (c *Cache) touch(e *list.Element) {
+	ent := e.Value.(*entry)
+	if ent.region != probation {
+		c.regions[ent.region].ll.MoveToFront(e)
+		return
+	}
+	c.detach(e)
+	ent.region = protected
+	c.push(ent)
+	// 保护区溢出的数据降级到试用区
+	for c.regions[protected].full(0) && c.regions[protected].ll.Len() > 1 {
+		demoted := c.detach(c.regions[protected].ll.Back())
+		demoted.region = probation
+		c.push(demoted)
+	}
+}
+
+// 主区域即将被淘汰的数据 优先从试用区选择
+func (c *Cache) victim() *list.Element {
+	if back := c.regions[probation].ll.Back(); back != nil {
+		return back
+	}
+	return c.regions[protected].ll.Back()
+}
+
+// 主区域再增加extra字节后是否超出容量
+func (c *Cache) mainFull(extra int) bool {
+	if c.capacity == 0 {
+		return false
+	}
+	length := c.regions[probation].length + c.regions[protected].length
+	return length+extra > c.capacity-c.regions[window].capacity
+}
+
+// 窗口溢出的数据作为候选者 根据访问频次决定是否进入主区域
+func (c *Cache) evict() {
+	w := c.regions[window]
+	for w.full(0) && w.ll.Len() > 0 {
+		candidate := c.detach(w.ll.Back())
+		delete(c.cache, candidate.key)
+		if c.admit(candidate) {
+			candidate.region = probation
+			c.push(candidate)
+			c.stats.Admitted++
+		} else {
+			c.evicted(candidate, lru.EvictCapacity)
+			c.stats.Rejected++
+		}
+	}
+	// 主区域的数据被更新或容量调整后可能超出容量
+	for c.mainFull(0) && c.victim() != nil {
+		c.removeElement(c.victim(), lru.EvictCapacity)
+	}
+	// 数据数量超出限制时 在窗口候选者和主区域淘汰者中淘汰访问频次较低的一个
+	for c.maxEntries != 0 && len(c.cache) > c.maxEntries {
+		candidate, victim := w.ll.Back(), c.victim()
+		// 主区域为空时候选者先进入主区域 再与窗口中的下一个候选者比较
+		if victim == nil && w.ll.Len() > 1 && !c.mainFull(candidate.Value.(*entry).size) {
+			ent := c.detach(candidate)
+			ent.region = probation
+			c.push(ent)
+			c.stats.Admitted++
+			continue
+		}
+		if candidate != nil && (victim == nil ||
+			c.sketch.estimate(candidate.Value.(*entry).key) <= c.sketch.estimate(victim.Value.(*entry).key)) {
+			victim = candidate
+		}
+		c.removeElement(victim, lru.EvictCapacity)
+	}
+}
+
+// 主区域有空间时直接接纳候选者 否则候选者频次高于所有需要淘汰的数据时才接纳
+func (c *Cache) admit(candidate *entry) bool {
+	size := candidate.size
+	if size > c.capacity-c.regions[window].capacity {
+		return false
+	}
+	freq := c.sketch.estimate(candidate.key)
+	// 先检查再淘汰 避免淘汰了部分数据后候选者仍被拒绝
+	need, victims := 0, make([]*list.Element, 0, 1)
+	for e := c.victim(); e != nil && c.mainFull(size-need); {
+		ent := e.Value.(*entry)
+		if c.sketch.estimate(ent.key) >= freq {
+			return false
+		}
+		victims = append(victims, e)
+		need += ent.size
+		if prev := e.Prev(); prev != nil {
+			e = prev
+		} else if ent.region == probation {
+			e = c.regions[protected].ll.Back()
+		} else {
+			e = nil
+		}
+	}
+	for _, e := range victims {
+		c.removeElement(e, lru.EvictCapacity)
+	}
+	return true
+}
+
+// 移除指定节点，删除过期时间，调用回调函数
+func (c *Cache) removeElement(e *list.Element, reason lru.EvictReason) {
+	ent := c.detach(e)
+	delete(c.cache, ent.key)
+	c.evicted(ent, reason)
+}
+
+func (c *Cache) evicted(ent *entry, reason lru.EvictReason) {
+	// 移除过期键
+	if !ent.value.Expire().IsZero() {
+		c.expires.ZRem(expiresZSetKey, ent.key)
+	}
+	if c.onEvicted != nil {
+		c.onEvicted(ent.key, ent.value, reason)
+	}
+}
+
+// RemoveExpired 主动移除最多n个已过期的键
+// 返回实际移除的数量
+func (c *Cache) RemoveExpired(n int) int {
+	return n - c.removeExpire(n)
+}
+
+// 移除过期的键
+// 返回未删除的数量
+func (c *Cache) removeExpire(n int) int {
+	for n > 0 && c.expires.ZCard(expiresZSetKey) > 0 {
+		values := c.expires.ZRangeWithScores(expiresZSetKey, 0, 0)
+		key, expireNano := values[0].(string), values[1].(int64)
+		// 第一个键都没超时，结束循环
+		if expireNano > time.Now().UnixNano() {
+			break
+		}
+		if element, ok := c.cache[key]; ok {
+			c.removeElement(element, lru.EvictExpired)
+		}
+		n--
+	}
+	return n
+}
@@ -0,0 +1,173 @@
+package tinylfu
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/juguagua/gCache/lru"
+)
+
+type String struct {
+	s      string
+	expire time.Time
+}
+
+func (s *String) Len() int {
+	return len(s.s)
+}
+
+func (s *String) Expire() time.Time {
+	return s.expire
+}
+
+func TestCache_Get(t *testing.T) {
+	c := New(0, nil)
+	testKey, testValue := "key1", &String{s: "value1"}
+	c.Add(testKey, testValue)
+	if value, ok := c.Get(testKey); !ok || value.(*String) != testValue {
+		t.Fatalf("cache hit %v:%v failed\n", testKey, testValue)
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("hit not cache key=key2\n")
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.HitRatio() != 0.5 {
+		t.Fatalf("unexpected stats %+v\n", stats)
+	}
+}
+
+func TestCache_Admission(t *testing.T) {
+	c := New(1000, nil)
+	hot := &String{s: "value"}
+	// 热点key积累访问频次
+	for i := 0; i < 10; i++ {
+		c.Get("hot")
+		c.Add("hot", hot)
+	}
+	// 一次性扫描不应挤掉热点key
+	for i := 0; i < 1000; i++ {
+		key := "scan" + strconv.Itoa(i)
+		c.Get(key)
+		c.Add(key, &String{s: "value"})
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatalf("hot key should survive the scan\n")
+	}
+	if c.Bytes() > 1000 {
+		t.Fatalf("bytes %d exceed capacity\n", c.Bytes())
+	}
+	if c.Stats().Rejected == 0 {
+		t.Fatalf("scan keys should be rejected\n")
+	}
+}
+
+func TestCache_Remove(t *testing.T) {
+	c := New(0, nil)
+	c.Add("key1", &String{s: "value1"})
+	c.Remove("key1")
+	if _, ok := c.Get("key1"); ok || c.Len() != 0 || c.Bytes() != 0 {
+		t.Fatalf("remove key1 failed, len=%d\n", c.Len())
+	}
+}
+
+func TestCache_Expire(t *testing.T) {
+	c := New(0, nil)
+	c.Add("key1", &String{s: "value1", expire: time.Now().Add(100 * time.Millisecond)})
+	if _, ok := c.Get("key1"); !ok {
+		t.Fatalf("get key1 failed\n")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := c.Get("key1"); ok || c.Len() != 0 {
+		t.Fatalf("expire key1 failed, len=%d\n", c.Len())
+	}
+}
+
+// 对比偏斜访问下W-TinyLFU和LRU的命中率
+func TestCache_HitRatio(t *testing.T) {
+	const capacity = 100 * 16
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.1, 1, 10000)
+
+	tiny, l := New(capacity, nil), lru.New(capacity, nil)
+	var lruHits, total int
+	for i := 0; i < 100000; i++ {
+		key := strconv.Itoa(int(zipf.Uint64()))
+		value := &String{s: "value"}
+		if _, ok := tiny.Get(key); !ok {
+			tiny.Add(key, value)
+		}
+		total++
+		if _, ok := l.Get(key); ok {
+			lruHits++
+		} else {
+			l.Add(key, value)
+		}
+	}
+	lruRatio := float64(lruHits) / float64(total)
+	t.Logf("tinylfu hit ratio %.4f, lru hit ratio %.4f", tiny.Stats().HitRatio(), lruRatio)
+	if tiny.Stats().HitRatio() < lruRatio {
+		t.Fatalf("tinylfu hit ratio %.4f lower than lru %.4f", tiny.Stats().HitRatio(), lruRatio)
+	}
+}
+
+func TestCache_MaxEntries(t *testing.T) {
+	c := New(1000, nil)
+	c.SetMaxEntries(2)
+	c.Add("key1", &String{s: "value1"})
+	for i := 0; i < 3; i++ {
+		c.Get("key1")
+	}
+	c.Add("key2", &String{s: "value2"})
+	// 数量超出限制时 访问频次较低的新数据被淘汰
+	for i := 0; i < 100; i++ {
+		c.Add(strconv.Itoa(i), &String{s: "value"})
+		if c.Len() > 2 {
+			t.Fatalf("max entries failed, len=%d\n", c.Len())
+		}
+	}
+	if _, ok := c.Get("key1"); !ok {
+		t.Fatalf("frequently used key1 should be kept\n")
+	}
+}
+
+func TestCache_AccountOverhead(t *testing.T) {
+	k1, v1 := "key1", &String{s: "value1", expire: time.Now().Add(time.Hour)}
+	c := New(0, nil)
+	c.Add(k1, v1)
+	logical := len(k1) + v1.Len()
+	if c.Bytes() != logical || c.LogicalBytes() != logical {
+		t.Fatalf("expect %d logical bytes, got %d\n", logical, c.Bytes())
+	}
+	if estimated := logical + lru.EntryOverhead + lru.ExpireOverhead; c.EstimatedBytes() != estimated {
+		t.Fatalf("expect %d estimated bytes, got %d\n", estimated, c.EstimatedBytes())
+	}
+	c.SetAccountOverhead(true)
+	if c.Bytes() != c.EstimatedBytes() || c.LogicalBytes() != logical {
+		t.Fatalf("expect bytes with overhead after enabling, got %d\n", c.Bytes())
+	}
+
+	// 计入额外开销后 容量只够存放一个entry
+	c = New(2*(logical+lru.EntryOverhead)-1, nil)
+	c.SetAccountOverhead(true)
+	for i := 0; i < 10; i++ {
+		c.Add("key"+strconv.Itoa(i), &String{s: "value1"})
+	}
+	if c.Bytes() > c.capacity || c.Len() > 1 {
+		t.Fatalf("expect at most 1 entry with overhead accounted, len=%d bytes=%d\n", c.Len(), c.Bytes())
+	}
+}
+
+// 容量较小时窗口仍能存放新数据 新数据不会直接参与准入
+func TestCache_MinWindow(t *testing.T) {
+	c := New(1<<10, nil)
+	for i := 0; i < 3; i++ {
+		c.Add("key"+strconv.Itoa(i), &String{s: "value"})
+	}
+	if stats := c.Stats(); stats.Admitted != 0 || stats.Rejected != 0 {
+		t.Fatalf("new entries should stay in the window, got %+v\n", stats)
+	}
+	if c.regions[window].ll.Len() != 3 {
+		t.Fatalf("expect 3 entries in window, got %d\n", c.regions[window].ll.Len())
+	}
+}
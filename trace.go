@@ -0,0 +1,42 @@
+package gcache
+
+import (
+	"context"
+
+	"github.com/juguagua/gCache/logging"
+	"github.com/juguagua/gCache/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// trace 模块为请求链路创建span 并通过grpc元数据在节点间传递trace上下文
+// 通过tracing.SetExporter开启追踪
+
+// startSpan 创建带有group和key哈希属性的span
+func startSpan(ctx context.Context, name string, group string, key string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, name)
+	if span != nil {
+		span.SetAttributes(tracing.String("group", group), tracing.String("key_hash", logging.HashKey(key)))
+	}
+	return ctx, span
+}
+
+// injectTrace 将trace上下文写入发往远程节点的grpc元数据
+func injectTrace(ctx context.Context) context.Context {
+	if tp := tracing.Traceparent(ctx); tp != "" {
+		return metadata.AppendToOutgoingContext(ctx, tracing.TraceparentHeader, tp)
+	}
+	return ctx
+}
+
+// traceInterceptor 从grpc元数据中提取远程节点传递的trace上下文
+func traceInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(tracing.TraceparentHeader); len(values) > 0 {
+			if sc, err := tracing.ParseTraceparent(values[0]); err == nil {
+				ctx = tracing.ContextWithRemoteSpanContext(ctx, sc)
+			}
+		}
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,74 @@
+package gcache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/juguagua/gCache/gcachepb"
+	"github.com/juguagua/gCache/logging"
+	"github.com/juguagua/gCache/tracing"
+	"google.golang.org/grpc"
+)
+
+// 测试trace上下文经由grpc传递到远程节点 远程节点的span与本地span属于同一条链路
+func TestTracePropagation(t *testing.T) {
+	e := tracing.NewInMemoryExporter()
+	tracing.SetExporter(e)
+	defer tracing.SetExporter(nil)
+
+	NewGroup("tracing", 2<<10, GetterFunc(func(ctx context.Context, key string) (ByteView, error) {
+		return NewByteView([]byte(key), time.Time{}), nil
+	}))
+	defer DestroyGroup("tracing")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(metricsInterceptor, traceInterceptor))
+	pb.RegisterGroupCacheServer(grpcServer, &server{addr: lis.Addr().String(), logger: logging.Nop()})
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{name: "gcache/" + lis.Addr().String(), conn: conn}
+	defer c.close()
+
+	ctx, root := tracing.Start(context.Background(), "root")
+	if view, err := c.Fetch(ctx, "tracing", "Tom"); err != nil || view.String() != "Tom" {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	root.End()
+
+	spans := make(map[string]tracing.SpanData)
+	for _, span := range e.Spans() {
+		if span.SpanContext.TraceID != root.SpanContext().TraceID {
+			t.Fatalf("span %s belongs to another trace", span.Name)
+		}
+		spans[span.Name] = span
+	}
+	parents := map[string]string{
+		"gcache.client.Fetch":      "root",
+		"gcache.server.Get":        "gcache.client.Fetch",
+		"gcache.Group.Get":         "gcache.server.Get",
+		"gcache.Group.load":        "gcache.Group.Get",
+		"gcache.Group.loadLocally": "gcache.Group.load",
+	}
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("missing span %s, got %v", name, e.Spans())
+		}
+		if span.Parent.SpanID != spans[parent].SpanContext.SpanID {
+			t.Fatalf("span %s should be child of %s", name, parent)
+		}
+	}
+	if !spans["gcache.server.Get"].Parent.Remote {
+		t.Fatal("server span should have a remote parent")
+	}
+}
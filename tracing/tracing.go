@@ -0,0 +1,276 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tracing 提供OpenTelemetry风格的最小化链路追踪
+// 每个span记录名称、起止时间、属性和错误 结束时交给Exporter导出
+// 跨节点时以W3C traceparent格式在请求元数据中传递trace上下文
+// 未设置Exporter时Start返回nil span 所有操作都是no-op 对热路径几乎没有开销
+
+// TraceID 一次完整调用链的标识
+type TraceID [16]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid 全零的TraceID无效
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// SpanID 单个span的标识
+type SpanID [8]byte
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid 全零的SpanID无效
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext 需要跨进程传递的span标识
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Remote  bool // 是否从远程节点传递而来
+}
+
+// IsValid TraceID和SpanID均有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Attribute span的属性
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// String 创建字符串属性
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData 已结束span的只读快照 交给Exporter导出
+type SpanData struct {
+	Name        string
+	SpanContext SpanContext
+	Parent      SpanContext // 父span 无父span时无效
+	Start       time.Time
+	End         time.Time
+	Attributes  []Attribute
+	Err         error
+}
+
+// Duration span的耗时
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+// Attr 返回属性key的值
+func (d SpanData) Attr(key string) (string, bool) {
+	for _, a := range d.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Exporter 导出已结束的span 需要并发安全
+type Exporter interface {
+	Export(span SpanData)
+}
+
+type exporterHolder struct {
+	e Exporter
+}
+
+var exporter atomic.Value // exporterHolder
+
+// SetExporter 设置全局Exporter 为nil时关闭追踪
+func SetExporter(e Exporter) {
+	exporter.Store(exporterHolder{e})
+}
+
+func currentExporter() Exporter {
+	h, _ := exporter.Load().(exporterHolder)
+	return h.e
+}
+
+// Span 一次操作的追踪记录 nil Span的所有方法都是no-op
+type Span struct {
+	mu       sync.Mutex
+	data     SpanData
+	ended    bool
+	exporter Exporter
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// Start 创建一个span 若ctx中已有span或远程span上下文则作为其子span
+// 返回携带新span的ctx 未设置Exporter时返回原ctx和nil
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	e := currentExporter()
+	if e == nil {
+		return ctx, nil
+	}
+	s := &Span{exporter: e}
+	s.data.Name = name
+	s.data.Start = time.Now()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		s.data.Parent = parent
+		s.data.SpanContext.TraceID = parent.TraceID
+	} else {
+		s.data.SpanContext.TraceID = newTraceID()
+	}
+	s.data.SpanContext.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// FromContext 返回ctx中的span 没有时返回nil
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SpanContextFromContext 返回ctx中当前span的上下文 没有本地span时返回远程span上下文
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := FromContext(ctx); s != nil {
+		return s.data.SpanContext
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemoteSpanContext 返回携带远程span上下文的ctx 之后Start的span将作为其子span
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContext 返回span的上下文
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetAttributes 设置span的属性
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetError 记录span的错误 err为nil时忽略
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err
+}
+
+// End 结束span并导出 重复调用只导出一次
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = append([]Attribute(nil), s.data.Attributes...)
+	s.mu.Unlock()
+	s.exporter.Export(data)
+}
+
+// TraceparentHeader 传递trace上下文的元数据名称
+const TraceparentHeader = "traceparent"
+
+// Traceparent 将ctx中的span上下文编码为W3C traceparent格式 没有时返回空字符串
+func Traceparent(ctx context.Context) string {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+}
+
+// ParseTraceparent 解析W3C traceparent格式的span上下文
+func ParseTraceparent(s string) (SpanContext, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	var sc SpanContext
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid trace id %q", parts[1])
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, fmt.Errorf("invalid span id %q", parts[2])
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	sc.Remote = true
+	return sc, nil
+}
+
+func newTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	rand.Read(id[:])
+	return id
+}
+
+// InMemoryExporter 将span保存在内存中的Exporter 用于测试
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter 创建内存Exporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// Export 实现Exporter接口
+func (e *InMemoryExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans 返回按结束顺序排列的所有span
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset 清空已导出的span
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
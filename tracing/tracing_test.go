@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	SetExporter(nil)
+	if _, span := Start(context.Background(), "noop"); span != nil {
+		t.Fatal("expect nil span without exporter")
+	}
+
+	e := NewInMemoryExporter()
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	ctx, parent := Start(context.Background(), "parent", String("group", "scores"))
+	_, child := Start(ctx, "child")
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+	parent.End()
+
+	spans := e.Spans()
+	if len(spans) != 2 || spans[0].Name != "child" || spans[1].Name != "parent" {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+	if spans[0].SpanContext.TraceID != spans[1].SpanContext.TraceID || spans[0].Parent != spans[1].SpanContext {
+		t.Fatalf("child should belong to parent: %+v", spans)
+	}
+	if spans[0].Err == nil {
+		t.Fatal("expect child error recorded")
+	}
+	if v, ok := spans[1].Attr("group"); !ok || v != "scores" {
+		t.Fatalf("expect group attribute, got %q", v)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	e := NewInMemoryExporter()
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	ctx, span := Start(context.Background(), "client")
+	header := Traceparent(ctx)
+	sc, err := ParseTraceparent(header)
+	if err != nil || sc.TraceID != span.SpanContext().TraceID || sc.SpanID != span.SpanContext().SpanID || !sc.Remote {
+		t.Fatalf("failed to round trip %q: %+v, %v", header, sc, err)
+	}
+
+	_, remote := Start(ContextWithRemoteSpanContext(context.Background(), sc), "server")
+	remote.End()
+	if got := e.Spans()[0]; got.Parent.SpanID != span.SpanContext().SpanID || !got.Parent.Remote {
+		t.Fatalf("server span should have remote parent, got %+v", got.Parent)
+	}
+
+	for _, bad := range []string{"", "00-abc-def-01", "00-" + sc.TraceID.String() + "-0000000000000000-01"} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Fatalf("expect error for %q", bad)
+		}
+	}
+}